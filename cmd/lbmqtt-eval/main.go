@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/command/analyze"
+	"location-based-mqtt-evaluation-tool/internal/command/bench"
+	"location-based-mqtt-evaluation-tool/internal/command/compare"
+	"location-based-mqtt-evaluation-tool/internal/command/metricsbench"
+	"location-based-mqtt-evaluation-tool/internal/command/pub"
+	"location-based-mqtt-evaluation-tool/internal/command/report"
+	"location-based-mqtt-evaluation-tool/internal/command/seal"
+	"location-based-mqtt-evaluation-tool/internal/command/search"
+	"location-based-mqtt-evaluation-tool/internal/command/sub"
+	"location-based-mqtt-evaluation-tool/internal/command/topicverify"
+	"location-based-mqtt-evaluation-tool/internal/command/verify"
+)
+
+// 計測に使う全てのツールを 1 つのバイナリのサブコマンドとして提供する
+func main() {
+	cli.Main("lbmqtt-eval", []cli.Command{
+		pub.Command,
+		sub.Command,
+		bench.Command,
+		search.Command,
+		analyze.Command,
+		compare.Command,
+		report.Command,
+		metricsbench.Command,
+		topicverify.Command,
+		seal.Command,
+		verify.Command,
+	}, os.Args[1:])
+}
@@ -0,0 +1,158 @@
+// Package arrival は Publish の間隔（到着間隔）の分布を表す
+// 分布は平均を 1 とした倍率で表し、-interval や -rate から求めた平均の間隔に掛けて使う
+package arrival
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dist は到着間隔の分布
+type Dist interface {
+	// NewSampler は rng を使って間隔を生成する Sampler を返す（Gorutine ごとに 1 つ使う）
+	NewSampler(rng *rand.Rand) Sampler
+	String() string
+}
+
+// Sampler は到着間隔を順に生成する
+type Sampler interface {
+	// Next は平均 mean の分布から次の間隔を返す
+	Next(mean time.Duration) time.Duration
+}
+
+// Parse は分布の指定を解釈する
+//
+//	fixed
+//	exp
+//	uniform:<jitter>
+//	pareto:<alpha>
+//	onoff:<on>,<off>
+func Parse(spec string) (Dist, error) {
+	kind, args := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, args = spec[:i], spec[i+1:]
+	}
+	params := []float64{}
+	if args != "" {
+		for _, v := range strings.Split(args, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, distError(spec)
+			}
+			params = append(params, f)
+		}
+	}
+	switch {
+	case kind == "fixed" && len(params) == 0:
+		return fixed{}, nil
+	case kind == "exp" && len(params) == 0:
+		return exponential{}, nil
+	case kind == "uniform" && len(params) == 1 && params[0] >= 0 && params[0] <= 1:
+		return uniform{jitter: params[0]}, nil
+	case kind == "pareto" && len(params) == 1 && params[0] > 1:
+		return pareto{alpha: params[0]}, nil
+	case kind == "onoff" && len(params) == 2 && params[0] > 0 && params[1] >= 0:
+		return onOff{on: params[0], off: params[1]}, nil
+	}
+	return nil, distError(spec)
+}
+
+func distError(spec string) error {
+	return ArrivalError{fmt.Sprintf("Invalid inter-arrival distribution (inputed: %v, available: fixed, exp, uniform:<jitter>, pareto:<alpha>, onoff:<on>,<off>)", spec)}
+}
+
+// Seed は seed から i 番目の Gorutine の乱数のシード値を導出する
+// 近いシード値の系列同士が似ないよう、SplitMix64 でかき混ぜる
+func Seed(seed int64, i int) int64 {
+	z := uint64(seed) + uint64(i+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// fixed は常に平均の間隔
+type fixed struct{}
+
+func (fixed) NewSampler(rng *rand.Rand) Sampler     { return fixed{} }
+func (fixed) Next(mean time.Duration) time.Duration { return mean }
+func (fixed) String() string                        { return "fixed" }
+
+// exponential は指数分布（Poisson 到着）
+type exponential struct{}
+
+func (exponential) NewSampler(rng *rand.Rand) Sampler { return sampleFunc(rng.ExpFloat64) }
+func (exponential) String() string                    { return "exp" }
+
+// uniform は平均の間隔の [1-jitter, 1+jitter] 倍の一様分布
+type uniform struct {
+	jitter float64
+}
+
+func (d uniform) NewSampler(rng *rand.Rand) Sampler {
+	return sampleFunc(func() float64 { return 1 + d.jitter*(2*rng.Float64()-1) })
+}
+func (d uniform) String() string { return fmt.Sprintf("uniform:%v", d.jitter) }
+
+// pareto は形状母数 alpha のパレート分布（裾の重い分布）
+// 平均が 1 になるよう、最小値を (alpha-1)/alpha とする
+type pareto struct {
+	alpha float64
+}
+
+func (d pareto) NewSampler(rng *rand.Rand) Sampler {
+	xm := (d.alpha - 1) / d.alpha
+	return sampleFunc(func() float64 { return xm / math.Pow(1-rng.Float64(), 1/d.alpha) })
+}
+func (d pareto) String() string { return fmt.Sprintf("pareto:%v", d.alpha) }
+
+// sampleFunc は平均を 1 とした倍率を返す関数を Sampler として使う
+type sampleFunc func() float64
+
+func (f sampleFunc) Next(mean time.Duration) time.Duration {
+	return time.Duration(float64(mean) * f())
+}
+
+// onOff は平均 on 秒の送信期間と平均 off 秒の休止期間（それぞれ指数分布）を繰り返す
+// 送信期間中は指数分布の間隔で送信し、休止期間を含めた長期の平均の間隔が mean になるよう、送信期間中の間隔を on/(on+off) 倍に縮める
+type onOff struct {
+	on, off float64
+}
+
+func (d onOff) NewSampler(rng *rand.Rand) Sampler {
+	return &onOffSampler{d: d, rng: rng, remaining: rng.ExpFloat64() * d.on}
+}
+func (d onOff) String() string { return fmt.Sprintf("onoff:%v,%v", d.on, d.off) }
+
+type onOffSampler struct {
+	d   onOff
+	rng *rand.Rand
+	// remaining は現在の送信期間の残り[sec]
+	remaining float64
+}
+
+func (s *onOffSampler) Next(mean time.Duration) time.Duration {
+	// left は送信期間だけを数えた次の送信までの時間[sec]
+	left := mean.Seconds() * s.d.on / (s.d.on + s.d.off) * s.rng.ExpFloat64()
+	gap := 0.
+	// 送信期間を使い切るたびに休止期間を挟み、新しい送信期間に入る
+	for left > s.remaining {
+		left -= s.remaining
+		gap += s.remaining + s.rng.ExpFloat64()*s.d.off
+		s.remaining = s.rng.ExpFloat64() * s.d.on
+	}
+	s.remaining -= left
+	return time.Duration((gap + left) * float64(time.Second))
+}
+
+// ArrivalError は到着間隔の分布の指定が不正であることを表す
+type ArrivalError struct {
+	Msg string
+}
+
+func (e ArrivalError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
@@ -0,0 +1,106 @@
+// Package backend は通常の MQTT ブローカと位置情報ベースのブローカを同じ操作で扱えるようにする
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"location-based-mqtt-evaluation-tool/internal/topic"
+)
+
+// Handler は受信したメッセージを処理する
+type Handler func(topic string, payload []byte)
+
+// Client は計測に使うクライアント
+type Client interface {
+	// Publish は (lat, lng) の位置から payload を Publish する
+	// QoS 1, 2 の場合は PUBACK, PUBCOMP を受信するまで待つ
+	Publish(lat, lng float64, payload []byte) error
+	// Subscribe は中心 (lat, lng)、半径 radiusKm の範囲から Publish されたメッセージを受信する
+	// radiusKm が 0 以下の場合は全てのメッセージ（dmb では (lat, lng) を含む 1 つのセルのメッセージ）を受信する
+	Subscribe(lat, lng, radiusKm float64, handler Handler) error
+	// PublishSignal は計測の終了などを知らせるシグナルを Publish する
+	PublishSignal(payload []byte) error
+	// SubscribeSignal はシグナルを受信する
+	SubscribeSignal(handler Handler) error
+	Disconnect()
+}
+
+// Options は接続時の設定
+type Options struct {
+	Host string
+	Port int
+	// Lat, Lng は位置情報ベースのブローカへ接続する際の初期位置
+	Lat float64
+	Lng float64
+	// Scheme は通常のブローカでトピック名とトピックフィルタを求めるのに使う
+	Scheme topic.Scheme
+	// FilterLevel は受信範囲をトピックフィルタに変換する際の位置の数
+	FilterLevel int
+	// QoS は計測用のメッセージを Publish する際、および計測用のメッセージとシグナルを Subscribe する際の QoS（dmb では Subscribe には使わない）
+	QoS byte
+}
+
+// signalTopic はシグナルを Publish するトピック
+const signalTopic = "/signal"
+
+var backends = map[string]func(opts Options) (Client, error){}
+
+func register(name string, connect func(opts Options) (Client, error)) {
+	backends[name] = connect
+}
+
+// Names は利用可能なバックエンドの名前を返す
+func Names() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Connect は name のバックエンドで接続する
+func Connect(name string, opts Options) (Client, error) {
+	connect, ok := backends[name]
+	if !ok {
+		return nil, BackendError{fmt.Sprintf("Unknown backend (inputed backend: %v, available: %v)", name, strings.Join(Names(), ", "))}
+	}
+	return connect(opts)
+}
+
+// ConnectRetry は name のバックエンドで接続し、失敗した場合は interval おきに retry 回まで再試行する
+// 失敗するたびに onError を呼び出し、全て失敗した場合は最後のエラーを返す
+func ConnectRetry(name string, opts Options, retry int, interval time.Duration, onError func(err error)) (Client, error) {
+	for i := 0; ; i++ {
+		c, err := Connect(name, opts)
+		if err == nil {
+			return c, nil
+		}
+		onError(err)
+		if i >= retry {
+			return nil, err
+		}
+		time.Sleep(interval)
+	}
+}
+
+// BackendError はバックエンドの操作に失敗したことを表す
+type BackendError struct {
+	Msg string
+}
+
+func (e BackendError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
+
+// UnsupportedError はバックエンドが対応していない操作であることを表す
+type UnsupportedError struct {
+	Msg string
+}
+
+func (e UnsupportedError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
@@ -0,0 +1,49 @@
+package backend
+
+import (
+	client "github.com/Takahiro55555/location-based-mqtt-client.golang"
+)
+
+func init() {
+	register("dmb", connectDMB)
+}
+
+// dmb は位置情報ベースのブローカへ、位置を指定して Publish・Subscribe する
+// トピックを直接扱えないため、シグナルには対応しない
+type dmb struct {
+	c   *client.Client
+	qos byte
+}
+
+func connectDMB(opts Options) (Client, error) {
+	c, err := client.Connect(opts.Host, uint16(opts.Port), opts.Lat, opts.Lng, 100., 1000)
+	if err != nil {
+		return nil, err
+	}
+	return &dmb{c: c, qos: opts.QoS}, nil
+}
+
+func (d *dmb) Publish(lat, lng float64, payload []byte) error {
+	return d.c.Publish(lat, lng, d.qos, false, string(payload))
+}
+
+// Subscribe は radiusKm が 0 以下の場合、全てのメッセージではなく (lat, lng) を含む 1 つのセルから受信する
+func (d *dmb) Subscribe(lat, lng, radiusKm float64, handler Handler) error {
+	if radiusKm < 0 {
+		radiusKm = 0
+	}
+	return d.c.UpdateSubscribe(lat, lng, radiusKm, wrap(handler))
+}
+
+func (d *dmb) PublishSignal(payload []byte) error {
+	return UnsupportedError{"The dmb backend does not support signals"}
+}
+
+func (d *dmb) SubscribeSignal(handler Handler) error {
+	return UnsupportedError{"The dmb backend does not support signals"}
+}
+
+func (d *dmb) Disconnect() {
+	d.c.Unsubscribe()
+	d.c.Disconnect(500)
+}
@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"fmt"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/geocheck"
+	"location-based-mqtt-evaluation-tool/internal/topic"
+)
+
+func init() {
+	register("single", connectSingle)
+}
+
+// single は通常の MQTT ブローカへ、位置をトピック名に変換して Publish・Subscribe する
+type single struct {
+	c           mqtt.Client
+	scheme      topic.Scheme
+	filterLevel int
+	qos         byte
+}
+
+func connectSingle(opts Options) (Client, error) {
+	if opts.Scheme == nil {
+		return nil, BackendError{"Topic scheme is required for the single backend"}
+	}
+	o := mqtt.NewClientOptions()
+	o.AddBroker(fmt.Sprintf("tcp://%s:%v", opts.Host, opts.Port))
+	c := mqtt.NewClient(o)
+	if token := c.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+	return &single{c: c, scheme: opts.Scheme, filterLevel: opts.FilterLevel, qos: opts.QoS}, nil
+}
+
+func (s *single) Publish(lat, lng float64, payload []byte) error {
+	t := s.scheme.Topic(s2.LatLngFromDegrees(lat, lng))
+	if token := s.c.Publish(t, s.qos, false, payload); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (s *single) Subscribe(lat, lng, radiusKm float64, handler Handler) error {
+	filters := s.scheme.All()
+	if radiusKm > 0 {
+		area := s2.CapFromCenterAngle(s2.PointFromLatLng(s2.LatLngFromDegrees(lat, lng)), geocheck.KmToAngle(radiusKm))
+		var err error
+		if filters, err = s.scheme.Filters(area, s.filterLevel); err != nil {
+			return err
+		}
+	}
+	for _, f := range filters {
+		if token := s.c.Subscribe(f, s.qos, wrap(handler)); token.Wait() && token.Error() != nil {
+			return token.Error()
+		}
+	}
+	return nil
+}
+
+func (s *single) PublishSignal(payload []byte) error {
+	if token := s.c.Publish(signalTopic, 1, false, payload); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (s *single) SubscribeSignal(handler Handler) error {
+	if token := s.c.Subscribe(signalTopic, s.qos, wrap(handler)); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (s *single) Disconnect() {
+	s.c.Disconnect(500)
+}
+
+func wrap(handler Handler) mqtt.MessageHandler {
+	return func(c mqtt.Client, m mqtt.Message) {
+		handler(m.Topic(), m.Payload())
+	}
+}
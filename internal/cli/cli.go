@@ -0,0 +1,171 @@
+// Package cli はサブコマンドの登録と実行、シェル補完スクリプトの生成を行う
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"location-based-mqtt-evaluation-tool/internal/provenance"
+)
+
+// Command はサブコマンド
+type Command struct {
+	Name    string
+	Summary string
+	// Define は fs にフラグを定義し、フラグの解釈後に実行する処理を返す
+	Define func(fs *flag.FlagSet) func()
+	// Values は値の候補が決まっているフラグの候補（シェル補完で使う）
+	Values map[string][]string
+	// Outputs は結果を書き出すファイルのパスを値に取るフラグの名前
+	// nil でない場合は -manifest フラグを追加し、実行後にこれらのファイルのハッシュを含む来歴を書き出す
+	Outputs []string
+}
+
+func (c Command) flagSet(program string) (*flag.FlagSet, func()) {
+	fs := flag.NewFlagSet(program+" "+c.Name, flag.ExitOnError)
+	run := c.Define(fs)
+	if c.Outputs == nil {
+		return fs, run
+	}
+	manifest := fs.String("manifest", "", "実行の来歴（設定、ビルド情報、マシンの概要、結果ファイルのハッシュ）を書き出すパス（verify で検査するには .manifest.json で終わる名前にする）")
+	return fs, func() {
+		if *manifest == "" {
+			run()
+			return
+		}
+		m := provenance.New(c.Name, fs)
+		run()
+		files := []string{}
+		for _, name := range c.Outputs {
+			files = append(files, fs.Lookup(name).Value.String())
+		}
+		if err := m.Write(*manifest, files...); err != nil {
+			log.Fatalf("Manifest error: %s", err)
+		}
+		log.Printf("Manifest : %v", *manifest)
+	}
+}
+
+// exitCode はサブコマンドの実行後に返す終了コード
+var exitCode int
+
+// SetExitCode はサブコマンドの実行後（来歴の書き出しなどを終えた後）に返す終了コードを設定する
+// 後処理を飛ばさずに失敗を伝えるため、サブコマンドの中では os.Exit の代わりにこれを使う
+func SetExitCode(code int) {
+	exitCode = code
+}
+
+// Main は args[0] のサブコマンドを実行する
+func Main(program string, commands []Command, args []string) {
+	log.SetOutput(os.Stdout)
+	log.SetFlags(log.Ltime | log.Lmicroseconds | log.Lshortfile)
+	if len(args) == 0 {
+		usage(os.Stderr, program, commands)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout, program, commands)
+		return
+	case "completion":
+		shell := "bash"
+		if len(args) > 1 {
+			shell = args[1]
+		}
+		if err := Completion(os.Stdout, program, commands, shell); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	for _, c := range commands {
+		if c.Name == args[0] {
+			fs, run := c.flagSet(program)
+			fs.Parse(args[1:])
+			run()
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command: %v\n\n", args[0])
+	usage(os.Stderr, program, commands)
+	os.Exit(2)
+}
+
+func usage(w io.Writer, program string, commands []Command) {
+	fmt.Fprintf(w, "Usage: %v <command> [flags]\n\nCommands:\n", program)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-14v %v\n", c.Name, c.Summary)
+	}
+	fmt.Fprintf(w, "  %-14v %v\n", "completion", "シェル補完スクリプトを出力する (bash, zsh)")
+	fmt.Fprintf(w, "\n各コマンドのフラグは \"%v <command> -h\" で表示する\n", program)
+}
+
+// Completion は shell 用の補完スクリプトを w に書き出す
+// zsh では bashcompinit を使って bash 用のスクリプトを読み込む
+func Completion(w io.Writer, program string, commands []Command, shell string) error {
+	if shell != "bash" && shell != "zsh" {
+		return CommandError{fmt.Sprintf("Unsupported shell: %v (available: bash, zsh)", shell)}
+	}
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+	names := []string{"completion"}
+	for _, c := range commands {
+		names = append(names, c.Name)
+	}
+	if shell == "zsh" {
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+	}
+	fmt.Fprintf(w, "%v() {\n", fn)
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `	if [ "${COMP_CWORD}" -eq 1 ]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"${cur}\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, `	case "${COMP_WORDS[1]}" in`)
+	fmt.Fprintln(w, "\tcompletion)")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"bash zsh\" -- \"${cur}\"))")
+	fmt.Fprintln(w, "\t\t;;")
+	for _, c := range commands {
+		fs, _ := c.flagSet(program)
+		flags := []string{}
+		fs.VisitAll(func(f *flag.Flag) {
+			flags = append(flags, "-"+f.Name)
+		})
+		fmt.Fprintf(w, "\t%v)\n", c.Name)
+		fmt.Fprintln(w, `		case "${prev}" in`)
+		keys := make([]string, 0, len(c.Values))
+		for k := range c.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "\t\t-%v)\n", k)
+			fmt.Fprintf(w, "\t\t\tCOMPREPLY=($(compgen -W %q -- \"${cur}\"))\n", strings.Join(c.Values[k], " "))
+			fmt.Fprintln(w, "\t\t\treturn")
+			fmt.Fprintln(w, "\t\t\t;;")
+		}
+		fmt.Fprintln(w, "\t\tesac")
+		fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"${cur}\"))\n", strings.Join(flags, " "))
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -o default -F %v %v\n", fn, program)
+	return nil
+}
+
+// CommandError はサブコマンドの実行に失敗したことを表す
+type CommandError struct {
+	Msg string
+}
+
+func (e CommandError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
@@ -0,0 +1,110 @@
+package analyze
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/heatmap"
+	"location-based-mqtt-evaluation-tool/internal/oracle"
+	"location-based-mqtt-evaluation-tool/internal/slo"
+)
+
+// Command は pub と sub のイベントログから、受信の過不足を求めるサブコマンド
+// 判定条件を満たさない場合は終了コード 1 で終了する
+var Command = cli.Command{
+	Name:    "analyze",
+	Summary: "イベントログから受信の過不足を求める",
+	Define:  define,
+}
+
+func define(fs *flag.FlagSet) func() {
+	pubLogs := fs.String("pub", "", "Publisher のイベントログ（カンマ区切りで複数指定可）")
+	subLogs := fs.String("sub", "", "Subscriber のイベントログ（カンマ区切りで複数指定可）")
+	graceMs := fs.Int64("grace", 1000, "受信範囲の設定・変更・終了の前後で、受信の有無を判定しない期間[ms]")
+	cellLevel := fs.Int("cellLevel", 10, "Publish 元の位置ごとに受信漏れ、Publish 数、受信数、遅延を集計するセルのレベル")
+	heatmapPath := fs.String("heatmap", "", "セルごとの集計結果を、セルの境界を多角形とする GeoJSON として書き出すパス")
+	assertions := &slo.Assertions{}
+	fs.Var(assertions, "assert", fmt.Sprintf("評価する判定条件（例: \"delivery >= 99.9%%\"、\"duplicates == 0\"）。繰り返し指定可。満たさない場合、または計測値が無く評価できない場合は終了コード 1 で終了する（指標: %v）", strings.Join(slo.Metrics(), ", ")))
+	assertFile := fs.String("assertFile", "", "判定条件を 1 行に 1 つずつ書いたファイル（-assert に加える）")
+	allowSkip := fs.Bool("allowSkip", false, "計測値が無く評価できない判定条件を満たしたものとみなす（既定では満たさなかったものとみなす）")
+	return func() {
+		log.Print("Starting...")
+
+		// オプションの表示
+		log.Printf("OPTION Publisher event logs       : %v", *pubLogs)
+		log.Printf("OPTION Subscriber event logs      : %v", *subLogs)
+		log.Printf("OPTION Grace period               : %v [ms]", *graceMs)
+		log.Printf("OPTION Miss cell level            : %v", *cellLevel)
+		log.Printf("OPTION Heatmap                    : %v", *heatmapPath)
+		log.Printf("OPTION Assertion file             : %v", *assertFile)
+		log.Printf("OPTION Allow skipped assertions   : %v", *allowSkip)
+
+		if *assertFile != "" {
+			if err := assertions.ReadFile(*assertFile); err != nil {
+				log.Fatalf("Assertion error: %s", err)
+			}
+		}
+		log.Printf("OPTION Assertions                 : %v", assertions)
+
+		if *pubLogs == "" || *subLogs == "" {
+			log.Fatal("Both -pub and -sub are required.")
+		}
+		result, err := oracle.Evaluate(strings.Split(*pubLogs, ","), strings.Split(*subLogs, ","), oracle.Options{GraceMs: *graceMs, CellLevel: *cellLevel})
+		if err != nil {
+			log.Fatalf("Oracle error: %s", err)
+		}
+
+		for _, c := range result.Clients {
+			printClient(c)
+		}
+		printClient(result.Total)
+		for _, c := range result.Cells {
+			if c.Missed == 0 {
+				continue
+			}
+			log.Printf("Miss : %v / %v [msg] (Cell: %v)", c.Missed, c.Expected, c.Cell.ToToken())
+		}
+		if *heatmapPath != "" {
+			if err := heatmap.Write(*heatmapPath, result.Cells); err != nil {
+				log.Fatalf("Heatmap error: %s", err)
+			}
+			log.Printf("Heatmap : %v [cells] (level: %v) -> %v", len(result.Cells), *cellLevel, *heatmapPath)
+		}
+		h := result.Latency
+		log.Printf("Latency : %v [ms] [n=%v] [min=%v] [max=%v] [p50=%v] [p90=%v] [p99=%v] [p99.9=%v] [negative=%v]",
+			h.Mean(), h.N(), h.Min(), h.Max(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), h.Quantile(0.999), h.Negative())
+
+		if len(*assertions) > 0 && slo.Print(slo.Evaluate(*assertions, measuredValues(result)), *allowSkip) {
+			cli.SetExitCode(1)
+		}
+	}
+}
+
+// measuredValues は判定条件の評価に使う計測値を求める（値を求められない指標は NaN）
+func measuredValues(result oracle.Result) slo.Values {
+	t := result.Total
+	values := slo.Values{
+		"delivery":   t.Recall() * 100,
+		"precision":  t.Precision() * 100,
+		"duplicates": float64(t.Duplicate),
+		"unexpected": float64(t.Unexpected),
+	}
+	if h := result.Latency; h.N() > 0 {
+		values["mean"] = h.Mean()
+		values["p50"] = float64(h.Quantile(0.5))
+		values["p90"] = float64(h.Quantile(0.9))
+		values["p99"] = float64(h.Quantile(0.99))
+		values["p99.9"] = float64(h.Quantile(0.999))
+		values["max"] = float64(h.Max())
+	}
+	return values
+}
+
+func printClient(c oracle.ClientResult) {
+	log.Printf("Delivery : expected=%v received=%v matched=%v missed=%v unexpected=%v ambiguous=%v unknown=%v duplicate=%v (Client: %v)",
+		c.Expected, c.Received, c.Matched, c.Missed, c.Unexpected, c.Ambiguous, c.Unknown, c.Duplicate, c.Client)
+	log.Printf("Recall : %v Precision : %v (Client: %v)", c.Recall(), c.Precision(), c.Client)
+}
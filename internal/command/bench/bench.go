@@ -0,0 +1,96 @@
+package bench
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"location-based-mqtt-evaluation-tool/internal/backend"
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/proc"
+)
+
+// Command は sub と pub を同じバイナリから順に起動して 1 回の計測を行うサブコマンド
+var Command = cli.Command{
+	Name:    "bench",
+	Summary: "sub と pub を起動して 1 回の計測を行う",
+	Define:  define,
+	Values:  map[string][]string{"backend": backend.Names()},
+}
+
+func define(fs *flag.FlagSet) func() {
+	backendName := fs.String("backend", "single", fmt.Sprintf("接続するブローカの種類 (%v)", strings.Join(backend.Names(), ", ")))
+	host := fs.String("host", "127.0.0.1", "ブローカーホスト名")
+	port := fs.Int("port", 1883, "ブローカーポート番号")
+	pubArgs := fs.String("pub", "", "pub に渡す追加のフラグ（空白区切り）")
+	subArgs := fs.String("sub", "", "sub に渡す追加のフラグ（空白区切り）")
+	delay := fs.Int("delay", 2, "sub を起動してから pub を起動するまでの秒数")
+	logDir := fs.String("logdir", "", "pub.log と sub.log を書き出すディレクトリ（省略時は標準出力のみ）")
+	return func() {
+		log.Print("Starting...")
+
+		// オプションの表示
+		log.Printf("OPTION Backend                    : %v", *backendName)
+		log.Printf("OPTION Broker hostname            : %v", *host)
+		log.Printf("OPTION Broker port                : %v", *port)
+		log.Printf("OPTION Publisher args             : %v", *pubArgs)
+		log.Printf("OPTION Subscriber args            : %v", *subArgs)
+		log.Printf("OPTION Publisher delay            : %v [sec]", *delay)
+		log.Printf("OPTION Log directory              : %v", *logDir)
+
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatalf("Executable error: %s", err)
+		}
+		if *logDir != "" {
+			if err := os.MkdirAll(*logDir, 0755); err != nil {
+				log.Fatalf("Log directory error: %s", err)
+			}
+		}
+		common := []string{"-backend", *backendName, "-host", *host, "-port", strconv.Itoa(*port)}
+		out := &proc.Output{}
+
+		sub, err := proc.Start(exe, "sub", append(common, strings.Fields(*subArgs)...), out, logPath(*logDir, "sub"), nil)
+		if err != nil {
+			log.Fatalf("Subscriber start error: %s", err)
+		}
+		signalCh := make(chan os.Signal, 1)
+		signal.Notify(signalCh, os.Interrupt)
+		time.Sleep(time.Second * time.Duration(*delay))
+		pub, err := proc.Start(exe, "pub", append(common, strings.Fields(*pubArgs)...), out, logPath(*logDir, "pub"), nil)
+		if err != nil {
+			sub.Process.Signal(os.Interrupt)
+			log.Fatalf("Publisher start error: %s", err)
+		}
+		go func() {
+			// 中断は子プロセスへそのまま伝える
+			for range signalCh {
+				log.Print("Interrupt detected.")
+				pub.Process.Signal(os.Interrupt)
+				sub.Process.Signal(os.Interrupt)
+			}
+		}()
+
+		pubErr := pub.Wait()
+		subErr := sub.Wait()
+		out.Wait()
+		if pubErr != nil || subErr != nil {
+			log.Fatalf("Benchmark failed (pub: %v, sub: %v)", pubErr, subErr)
+		}
+		log.Print("Finished measurement.")
+	}
+}
+
+// logPath は logDir に書き出す name の出力のパスを返す（logDir が空の場合は空）
+func logPath(logDir, name string) string {
+	if logDir == "" {
+		return ""
+	}
+	return filepath.Join(logDir, name+".log")
+}
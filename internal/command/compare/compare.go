@@ -0,0 +1,239 @@
+package compare
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/result"
+	"location-based-mqtt-evaluation-tool/internal/stats"
+)
+
+// Command は複数の計測結果の組を比較し、性能の低下を検出するサブコマンド
+// 閾値を超える性能の低下があった場合は終了コード 1 で終了する
+var Command = cli.Command{
+	Name:    "compare",
+	Summary: "計測結果の組を比較し、性能の低下を検出する",
+	Define:  define,
+}
+
+// minTestSamples はこれより標本が少ない場合は検定せず、閾値だけで性能の低下を判定する
+const minTestSamples = 3
+
+// groups は "-group <名前>=<ログファイル>,..." を繰り返し指定したもの
+type groups struct {
+	names []string
+	paths map[string][]string
+}
+
+func (g *groups) String() string {
+	return strings.Join(g.names, ",")
+}
+
+func (g *groups) Set(v string) error {
+	kv := strings.SplitN(v, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return cli.CommandError{Msg: fmt.Sprintf("Invalid group: %v (expected: <name>=<log file>,...)", v)}
+	}
+	if _, ok := g.paths[kv[0]]; !ok {
+		g.names = append(g.names, kv[0])
+	}
+	for _, pattern := range strings.Split(kv[1], ",") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return cli.CommandError{Msg: fmt.Sprintf("No log file matches %v", pattern)}
+		}
+		g.paths[kv[0]] = append(g.paths[kv[0]], matches...)
+	}
+	return nil
+}
+
+func define(fs *flag.FlagSet) func() {
+	g := &groups{paths: map[string][]string{}}
+	fs.Var(g, "group", "比較する計測結果の組 (<名前>=<ログファイル>,...、ワイルドカード可)。2 つ以上指定し、最初の組を基準とする")
+	ignore := fs.String("ignore", "Process id,Seed,Event log,Broker hostname,Broker port", "計測条件を揃える際に無視するオプション名（カンマ区切り）")
+	percentiles := fs.String("percentiles", "50,90,99", "比較するメッセージごとの遅延のパーセンタイル（カンマ区切り、50, 90, 99, 99.9 のいずれか）。sub が ID ごとに表示する Latency total の値を標本とする")
+	alpha := fs.Float64("alpha", 0.05, "有意水準")
+	confidence := fs.Float64("confidence", 0.95, "ブートストラップ信頼区間の信頼水準")
+	resamples := fs.Int("resamples", 1000, "ブートストラップの復元抽出の回数")
+	seed := fs.Int64("seed", 1, "ブートストラップのシード値")
+	maxRateDrop := fs.Float64("maxRateDrop", 5, "許容する Publish レートの中央値の低下[%]")
+	maxLatencyRise := fs.Float64("maxLatencyRise", 10, "許容する遅延のパーセンタイルの増加[%]")
+	maxLossRise := fs.Float64("maxLossRise", 0.01, "許容する受信漏れの割合の増加")
+	return func() {
+		log.Print("Starting...")
+
+		// オプションの表示
+		log.Printf("OPTION Groups                     : %v", g)
+		log.Printf("OPTION Ignored options            : %v", *ignore)
+		log.Printf("OPTION Latency percentiles        : %v", *percentiles)
+		log.Printf("OPTION Significance level         : %v", *alpha)
+		log.Printf("OPTION Confidence level           : %v", *confidence)
+		log.Printf("OPTION Bootstrap resamples        : %v", *resamples)
+		log.Printf("OPTION Seed                       : %v", *seed)
+		log.Printf("OPTION Max rate drop              : %v [%%]", *maxRateDrop)
+		log.Printf("OPTION Max latency rise           : %v [%%]", *maxLatencyRise)
+		log.Printf("OPTION Max loss rise              : %v", *maxLossRise)
+
+		if len(g.names) < 2 {
+			log.Fatal("At least two -group are required.")
+		}
+		qs := []float64{}
+		for _, v := range strings.Split(*percentiles, ",") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || !available(q) {
+				log.Fatalf("Invalid percentile: %v (available: %v)", v, result.LatencyPercentiles)
+			}
+			qs = append(qs, q)
+		}
+		ignored := map[string]bool{}
+		for _, k := range strings.Split(*ignore, ",") {
+			ignored[strings.TrimSpace(k)] = true
+		}
+
+		// 組ごとに、計測条件ごとの実行結果に分ける
+		scenarios := map[string]map[string][]*result.Run{}
+		pubs := map[string][]*result.Run{}
+		for _, name := range g.names {
+			for _, path := range g.paths[name] {
+				r, err := result.Read(path)
+				if err != nil {
+					log.Fatalf("Log file error: %s", err)
+				}
+				key := r.Scenario(ignored)
+				if scenarios[key] == nil {
+					scenarios[key] = map[string][]*result.Run{}
+				}
+				scenarios[key][name] = append(scenarios[key][name], r)
+				if !r.IsSubscriber() {
+					pubs[name] = append(pubs[name], r)
+				}
+			}
+		}
+		keys := make([]string, 0, len(scenarios))
+		for k := range scenarios {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		c := comparer{alpha: *alpha, confidence: *confidence, resamples: *resamples, rng: rand.New(rand.NewSource(*seed))}
+		base := g.names[0]
+		for _, key := range keys {
+			runs := scenarios[key]
+			if runs[base] == nil {
+				log.Printf("[Warning] Scenario is not in the base group %v: %v", base, key)
+				continue
+			}
+			for _, name := range g.names[1:] {
+				if runs[name] == nil {
+					log.Printf("[Warning] Scenario is not in the group %v: %v", name, key)
+					continue
+				}
+				log.Printf("Scenario : %v (Runs: %v=%v %v=%v)", key, base, len(runs[base]), name, len(runs[name]))
+				c.compare("throughput", base, name, rates(runs[base]), rates(runs[name]), stats.Median, -*maxRateDrop, " [pub/s]", true)
+				// パーセンタイルごとに、各実行の ID ごとの値を標本として比較する
+				for _, q := range qs {
+					c.compare(fmt.Sprintf("latency p%v", q), base, name, quantiles(runs[base], q), quantiles(runs[name], q), stats.Median, *maxLatencyRise, " [ms]", true)
+				}
+				if la, lb := losses(runs[base], pubs[base]), losses(runs[name], pubs[name]); len(la) > 0 && len(lb) > 0 {
+					c.compare("loss", base, name, la, lb, stats.Mean, *maxLossRise, "", false)
+				}
+			}
+		}
+
+		if c.regressions > 0 {
+			log.Printf("Comparison failed: %v regression(s)", c.regressions)
+			cli.SetExitCode(1)
+			return
+		}
+		log.Print("No regression detected.")
+	}
+}
+
+type comparer struct {
+	alpha       float64
+	confidence  float64
+	resamples   int
+	rng         *rand.Rand
+	regressions int
+}
+
+// compare は組 base の a を基準とした組 name の b の stat の変化を表示し、threshold を超えて悪化していれば性能の低下とする
+// relative が true の場合は threshold を変化率[%]として扱う。threshold が負の場合は値の低下を悪化とする
+func (c *comparer) compare(metric, base, name string, a, b []float64, stat func([]float64) float64, threshold float64, unit string, relative bool) {
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
+	va, vb := stat(a), stat(b)
+	lo, hi := stats.BootstrapCI(a, b, stat, c.resamples, c.confidence, c.rng)
+	_, p := stats.MannWhitneyU(a, b)
+	delta := vb - va
+	change := fmt.Sprintf("%+.4g", delta)
+	if relative {
+		delta = delta / va * 100
+		change = fmt.Sprintf("%+.2f%%", delta)
+	}
+	label := fmt.Sprintf("%v vs %v", name, base)
+	log.Printf("Compare %v : %v=%.4g %v=%.4g%v delta=%v CI=[%+.4g, %+.4g] p=%.4g [n=%v/%v] (Group: %v)",
+		metric, base, va, name, vb, unit, change, lo, hi, p, len(a), len(b), label)
+
+	worse := (threshold < 0 && delta < threshold) || (threshold >= 0 && delta > threshold)
+	if !worse || math.IsNaN(delta) {
+		return
+	}
+	tested := len(a) >= minTestSamples && len(b) >= minTestSamples
+	if tested && p >= c.alpha {
+		log.Printf("[Warning] %v changed by %v but it is not significant (p=%.4g) (Group: %v)", metric, change, p, label)
+		return
+	}
+	if !tested {
+		log.Printf("[Warning] Too few samples to test the significance of %v (Group: %v)", metric, label)
+	}
+	log.Printf("Regression : %v changed by %v beyond the threshold %v (Group: %v)", metric, change, math.Abs(threshold), label)
+	c.regressions++
+}
+
+func rates(runs []*result.Run) []float64 {
+	xs := []float64{}
+	for _, r := range runs {
+		xs = append(xs, r.Rates()...)
+	}
+	return xs
+}
+
+func quantiles(runs []*result.Run, q float64) []float64 {
+	xs := []float64{}
+	for _, r := range runs {
+		xs = append(xs, r.Percentiles(q)...)
+	}
+	return xs
+}
+
+func available(q float64) bool {
+	for _, v := range result.LatencyPercentiles {
+		if v == q {
+			return true
+		}
+	}
+	return false
+}
+
+func losses(runs, pubs []*result.Run) []float64 {
+	xs := []float64{}
+	for _, r := range runs {
+		if l, ok := r.Loss(pubs); ok {
+			xs = append(xs, l)
+		}
+	}
+	return xs
+}
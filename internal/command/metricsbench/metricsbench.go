@@ -0,0 +1,137 @@
+package metricsbench
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/resource"
+	"location-based-mqtt-evaluation-tool/internal/timeseries"
+)
+
+// Command は計測値の記録にかかるオーバーヘッドを、ブローカを使わずに計測するサブコマンド
+var Command = cli.Command{
+	Name:    "metrics-bench",
+	Summary: "計測値の記録にかかるオーバーヘッドを計測する",
+	Define:  define,
+	Values:  map[string][]string{"mode": {"sharded", "shared"}},
+}
+
+func define(fs *flag.FlagSet) func() {
+	rutines := fs.Int("rutines", 100, "Gorutine の数")
+	rate := fs.Int("rate", 100000, "全 Gorutine 合計の記録レート[msg/s]（0 の場合は上限なし）")
+	t := fs.Int("time", 10, "計測時間[sec]")
+	idNum := fs.Int("ids", 10, "記録先の ID の数")
+	mode := fs.String("mode", "sharded", "書き込み方式（sharded: Gorutine ごとの Writer, shared: 全 Gorutine で共有する Writer）")
+	return func() {
+		log.Print("Starting...")
+
+		// オプションの表示
+		log.Printf("OPTION Gorutine num               : %v", *rutines)
+		log.Printf("OPTION Target rate                : %v [msg/s]", *rate)
+		log.Printf("OPTION Measurement time           : %v [s]", *t)
+		log.Printf("OPTION ID num                     : %v", *idNum)
+		log.Printf("OPTION Mode                       : %v", *mode)
+
+		if *mode != "sharded" && *mode != "shared" {
+			log.Fatalf("Unknown mode: %v", *mode)
+		}
+		ids := make([]string, *idNum)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("bench-%v", i)
+		}
+
+		recorder := timeseries.NewRecorder(time.Second, 3600)
+		var shared *timeseries.Writer
+		if *mode == "shared" {
+			shared = recorder.NewWriter()
+		}
+		var count int64
+		cpuStart := resource.CPUTime()
+		deadline := time.Now().Add(time.Second * time.Duration(*t))
+		wg := sync.WaitGroup{}
+		log.Print("Starting goroutine...")
+		for i := 0; i < *rutines; i++ {
+			writer := shared
+			if writer == nil {
+				writer = recorder.NewWriter()
+			}
+			wg.Add(1)
+			go func(i int, writer *timeseries.Writer) {
+				defer wg.Done()
+				atomic.AddInt64(&count, record(writer, ids, i, float64(*rate)/float64(*rutines), deadline))
+			}(i, writer)
+		}
+		log.Print("Done launching goroutine.")
+
+		cursor := timeseries.NewCursor()
+		for time.Now().Before(deadline) {
+			printRates(recorder.Collect(cursor, time.Now()))
+			time.Sleep(time.Millisecond * 500)
+		}
+		wg.Wait()
+		recorder.CloseAll()
+		printRates(recorder.Flush(cursor))
+		cpu := resource.CPUTime() - cpuStart
+
+		if count == 0 {
+			log.Print("No message was recorded.")
+			return
+		}
+		log.Printf("Recorded total                    : %v [msg]", count)
+		log.Printf("Recorded rate                     : %v [msg/s]", float64(count)/float64(*t))
+		if cpu <= 0 {
+			// getrusage の無い OS では CPU 時間を取得できないため、go test -bench WriterAdd で比較する
+			log.Print("CPU time                          : unavailable on this platform")
+			return
+		}
+		// 記録処理だけでなく、送信間隔の調整や読み出し処理の CPU 時間も含む
+		log.Printf("CPU time                          : %v [ms]", cpu.Milliseconds())
+		log.Printf("Overhead                          : %v [ns/msg]", float64(cpu.Nanoseconds())/float64(count))
+	}
+}
+
+// record は rate [msg/s] の間隔で deadline まで値を記録し、記録数を返す
+func record(writer *timeseries.Writer, ids []string, offset int, rate float64, deadline time.Time) int64 {
+	const batch = 1000
+	start := time.Now()
+	n := int64(0)
+	for {
+		now := time.Now()
+		if !now.Before(deadline) {
+			return n
+		}
+		target := int64(batch)
+		if rate > 0 {
+			target = int64(rate*now.Sub(start).Seconds()) - n
+			if target <= 0 {
+				time.Sleep(time.Millisecond)
+				continue
+			}
+		}
+		// 受信時と同様に、1 件ごとに時刻を取得してから記録する
+		for i := int64(0); i < target; i++ {
+			at := time.Now()
+			writer.Add(ids[(offset+int(n+i))%len(ids)], at, at.UnixNano()%1000)
+		}
+		n += target
+	}
+}
+
+func printRates(points []timeseries.Point) {
+	rates := map[time.Time]int64{}
+	order := []time.Time{}
+	for _, p := range points {
+		if _, ok := rates[p.Start]; !ok {
+			order = append(order, p.Start)
+		}
+		rates[p.Start] += p.N
+	}
+	for _, t := range order {
+		log.Printf("Record rate: %v [msg/s]", rates[t])
+	}
+}
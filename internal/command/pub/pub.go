@@ -0,0 +1,851 @@
+package pub
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"location-based-mqtt-evaluation-tool/internal/arrival"
+	"location-based-mqtt-evaluation-tool/internal/backend"
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/histogram"
+	"location-based-mqtt-evaluation-tool/internal/payload"
+	"location-based-mqtt-evaluation-tool/internal/replay"
+	"location-based-mqtt-evaluation-tool/internal/resource"
+	"location-based-mqtt-evaluation-tool/internal/runlog"
+	"location-based-mqtt-evaluation-tool/internal/timeseries"
+	"location-based-mqtt-evaluation-tool/internal/topic"
+	"location-based-mqtt-evaluation-tool/internal/trace"
+)
+
+// Command は計測用のメッセージを Publish するサブコマンド
+var Command = cli.Command{
+	Name:    "pub",
+	Summary: "計測用のメッセージを Publish する",
+	Define:  define,
+	Outputs: []string{"eventlog"},
+	Values:  map[string][]string{"backend": backend.Names(), "codec": payload.Names(), "scheme": topic.Names()},
+}
+
+func define(fs *flag.FlagSet) func() {
+	backendName := fs.String("backend", "single", fmt.Sprintf("接続するブローカの種類 (%v)", strings.Join(backend.Names(), ", ")))
+	host := fs.String("host", "127.0.0.1", "ブローカーホスト名")
+	port := fs.Int("port", 1883, "ブローカーポート番号")
+	pid := fs.String("pid", "", "Subscriber 側がプロセスを識別するための ID")
+	msglen := fs.Int("msglen", 100, "送信するメッセージのバイト数")
+	sizes := fs.String("sizes", "", "送信するメッセージのバイト数の分布 (fixed:<size>, uniform:<min>-<max>, weighted:<size>=<weight>,..., lognormal:<median>,<sigma>[,<min>,<max>])。指定した場合は -msglen より優先する")
+	codecName := fs.String("codec", "json", fmt.Sprintf("メッセージの符号化方式 (%v)", strings.Join(payload.Names(), ", ")))
+	clientNum := fs.Int("clients", 100, "クライアント数")
+	rutines := fs.Int("rutines", 100, "Gorutine の数")
+	t := fs.Int("time", 100, "計測時間[sec]（-warmup、-cooldown の時間は含まない）")
+	warmUp := fs.Int("warmup", 0, "計測前に Publish する時間[sec]（記録するが集計から除く）")
+	coolDown := fs.Int("cooldown", 0, "計測後に Publish する時間[sec]（記録するが集計から除く）")
+	interval := fs.Int("interval", 100, "Publish した後に sleep する時間[ms]")
+	rate := fs.Float64("rate", 0, "全ての Gorutine を合わせた Publish の頻度[pub/s]（0 より大きい場合は -interval の代わりに、各 Gorutine が一定の間隔で Publish する）")
+	arrivalSpec := fs.String("arrival", "fixed", "各 Gorutine の Publish の間隔の分布 (fixed, exp, uniform:<jitter>, pareto:<alpha>, onoff:<on>,<off>)。平均は -interval または -rate から求めた間隔とし、乱数は -seed から Gorutine ごとに導出する")
+	burst := fs.Int("burst", 0, "バースト送信で各 Gorutine が一斉に Publish するメッセージ数（0 の場合はバースト送信しない）。バースト送信では -time、-warmup、-cooldown、-interval、-rate、-arrival を使わず、Publish する範囲は -prefix で指定する")
+	burstAt := fs.String("burstAt", "", "最初のバーストの時刻（UNIX 時間[ms] または RFC3339）。複数のプロセスで同じ時刻を指定すると一斉に Publish する（省略時は Gorutine の起動の 1 秒後）")
+	bursts := fs.Int("bursts", 1, "バーストの回数")
+	burstEvery := fs.Int("burstEvery", 10, "バーストの間隔[sec]（sub は受信が途絶えてから -waitsec 秒で終了するため、sub の -waitsec はこれより長くする）")
+	retry := fs.Int("retry", 0, "ブローカーへの接続に失敗した場合に 1 秒おきに再試行する回数")
+	qos := fs.Int("qos", 0, "計測用のメッセージを Publish する際の QoS (0, 1, 2)")
+	bufferSec := fs.Int("buffer", 3600, "保持する計測結果の秒数")
+	prefix := fs.String("prefix", "", "Publish する位置を選ぶ範囲を表す -scheme の方式のトピック名の接頭辞（省略時は s2 は /0、geohash は /s、grid は /90/180）")
+	locLevel := fs.Int("locLevel", topic.MaxLevel, "Publish する位置を選ぶ領域のレベル（方式ごとの最大値を超える場合は最大値）")
+	schemeName := fs.String("scheme", "s2", fmt.Sprintf("トピック名の方式 (%v)（single のみ）", strings.Join(topic.Names(), ", ")))
+	topicLevel := fs.Int("topicLevel", -1, "トピック名で表す位置の数（負の場合は方式ごとの最大値。s2 では 30 で、根を含めて 31 階層（62 byte）となり、以前の single-publisher の 32 階層（65 byte）より浅い）（single のみ）")
+	topicDepth := fs.Int("topicDepth", 0, "根を除いたトピックの階層数（0 の場合は -topicLevel と同じ）（single のみ）")
+	topicSep := fs.String("topicSep", "", "同じ階層に並べる位置同士の区切り文字（single のみ）")
+	sweepDepth := fs.String("sweepDepth", "", "指定した階層数（カンマ区切り）ごとに -time 秒ずつ順に計測する（single のみ）")
+	eventLog := fs.String("eventlog", "", "Publish したメッセージを記録するイベントログのパス（oracle で使用）")
+	tracePath := fs.String("trace", "", "移動軌跡（GeoJSON の LineString、または id, timestamp, lat, lng の列を持つ CSV）のパス。軌跡ごとに 1 つのクライアントと Gorutine を割り当て（-clients、-rutines の代わりに軌跡の数を使う）、各軌跡の期間内のみその時点の位置から Publish する。-prefix、-locLevel は使わない")
+	traceSpeed := fs.Float64("traceSpeed", 1, "軌跡の時間の圧縮率（例: 60 の場合は軌跡の 1 分を 1 秒で再生する）。軌跡の時刻は全ての軌跡のうち最も早い時刻を計測の開始（-warmup を含む）に合わせる")
+	recordPath := fs.String("record", "", "Publish の予定（計測の開始からの時刻、Gorutine、位置、メッセージサイズ）を記録するファイルのパス（-replay で再現する）")
+	replayPath := fs.String("replay", "", "-record で記録した Publish の予定を、同じ時刻、位置、メッセージサイズ、padding で再現する。ID（-pid を省略した場合）、符号化方式、Gorutine 数、計測の段階は記録に従い、-time、-warmup、-cooldown、-interval、-rate、-arrival、-msglen、-sizes、-prefix、-locLevel は使わない")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "pid や padding を生成するためのシード値")
+	resourceSec := fs.Int("resource", 1, "自身の資源使用量（CPU、RSS、goroutine 数、GC、ファイルディスクリプタ）を記録する間隔[sec]（0 の場合は記録しない）")
+	cpuLimit := fs.Float64("cpuLimit", 90, "自身がボトルネックになっているとみなす CPU 使用率[%]（GOMAXPROCS 個のコアに対する割合）")
+	gcLimit := fs.Float64("gcLimit", 10, "自身がボトルネックになっているとみなす GC による停止時間の割合[%]")
+	return func() {
+		log.Print("Starting...")
+		rand.Seed(*seed)
+
+		var script *replay.Schedule
+		if *replayPath != "" {
+			var err error
+			if script, err = replay.Read(*replayPath); err != nil {
+				log.Fatalf("Replay error: %s", err)
+			}
+			if *pid == "" {
+				*pid = script.ID
+			}
+			*codecName, *rutines, *rate = script.Codec, script.Goroutines, script.Rate
+		}
+		var traces []*trace.Trace
+		if *tracePath != "" {
+			if script != nil {
+				log.Fatalf("Trace error: -trace cannot be combined with -replay")
+			}
+			if *traceSpeed <= 0 {
+				log.Fatalf("Trace error: -traceSpeed must be positive")
+			}
+			var err error
+			if traces, err = trace.Load(*tracePath); err != nil {
+				log.Fatalf("Trace error: %s", err)
+			}
+			*clientNum, *rutines = len(traces), len(traces)
+		}
+		if *clientNum < 1 {
+			log.Fatalf("Invalid client num: %v (must be 1 or more)", *clientNum)
+		}
+		if *pid == "" {
+			*pid = randString1(10)
+		}
+
+		// オプションの表示
+		log.Printf("OPTION Backend                    : %v", *backendName)
+		log.Printf("OPTION Broker hostname            : %v", *host)
+		log.Printf("OPTION Broker port                : %v", *port)
+		log.Printf("OPTION Message length             : %v", *msglen)
+		log.Printf("OPTION Message size distribution  : %v", *sizes)
+		log.Printf("OPTION Message codec              : %v", *codecName)
+		log.Printf("OPTION Client num                 : %v", *clientNum)
+		log.Printf("OPTION Gorutine num               : %v", *rutines)
+		log.Printf("OPTION Measurement time           : %v [s]", *t)
+		log.Printf("OPTION Warm-up time               : %v [s]", *warmUp)
+		log.Printf("OPTION Cool-down time             : %v [s]", *coolDown)
+		log.Printf("OPTION Publish interval           : %v [ms]", *interval)
+		log.Printf("OPTION Publish rate               : %v [pub/s]", *rate)
+		log.Printf("OPTION Inter-arrival distribution : %v", *arrivalSpec)
+		log.Printf("OPTION Burst size                 : %v", *burst)
+		log.Printf("OPTION Burst start                : %v", *burstAt)
+		log.Printf("OPTION Burst count                : %v", *bursts)
+		log.Printf("OPTION Burst interval             : %v [sec]", *burstEvery)
+		log.Printf("OPTION Connect retry              : %v", *retry)
+		log.Printf("OPTION QoS                        : %v", *qos)
+		log.Printf("OPTION Process id                 : %v", *pid)
+		log.Printf("OPTION Buffer                     : %v [sec]", *bufferSec)
+		log.Printf("OPTION Process prefix             : %v", *prefix)
+		log.Printf("OPTION Location level             : %v", *locLevel)
+		log.Printf("OPTION Topic scheme               : %v", *schemeName)
+		log.Printf("OPTION Topic level                : %v", *topicLevel)
+		log.Printf("OPTION Topic depth                : %v", *topicDepth)
+		log.Printf("OPTION Topic separator            : %v", *topicSep)
+		log.Printf("OPTION Topic depth sweep          : %v", *sweepDepth)
+		log.Printf("OPTION Event log                  : %v", *eventLog)
+		log.Printf("OPTION Trace                      : %v", *tracePath)
+		log.Printf("OPTION Trace speed                : %v", *traceSpeed)
+		log.Printf("OPTION Record schedule            : %v", *recordPath)
+		log.Printf("OPTION Replay schedule            : %v", *replayPath)
+		log.Printf("OPTION Seed                       : %v", *seed)
+		log.Printf("OPTION Resource interval          : %v [sec]", *resourceSec)
+		log.Printf("OPTION CPU limit                  : %v [%%]", *cpuLimit)
+		log.Printf("OPTION GC pause limit             : %v [%%]", *gcLimit)
+
+		if *qos < 0 || *qos > 2 {
+			log.Fatalf("Invalid QoS: %v", *qos)
+		}
+
+		// 送信メッセージの生成
+		codec, err := payload.Lookup(*codecName)
+		if err != nil {
+			log.Fatalf("Codec error: %s", err)
+		}
+		if *sizes == "" {
+			*sizes = fmt.Sprintf("fixed:%v", *msglen)
+		}
+		dist, err := payload.ParseSizeDist(*sizes)
+		if err != nil {
+			log.Fatalf("Message size error: %s", err)
+		}
+		// 計測の段階、バーストの予定時刻は使う場合のみメッセージの長さに含まれるため、使う場合の最も長いメッセージで確かめる
+		sample := payload.Measurement{ID: *pid, TimeMs: time.Now().UnixNano() / int64(time.Millisecond), Lat: 90, Lng: 180}
+		if *warmUp > 0 || *coolDown > 0 {
+			sample.Phase = payload.PhaseCoolDown
+		}
+		if *burst > 0 {
+			sample.BurstMs = sample.TimeMs
+		}
+		if script != nil {
+			sample.Phase, sample.BurstMs = 0, 0
+			for _, es := range script.Entries {
+				for _, e := range es {
+					if e.Phase != 0 {
+						sample.Phase = payload.PhaseCoolDown
+					}
+					if e.Burst {
+						sample.BurstMs = sample.TimeMs
+					}
+				}
+			}
+		}
+		minSize := payload.MinSize(codec, sample)
+		if dist.Min() < minSize {
+			log.Fatalf("Message size error: %v [byte] is smaller than the minimum payload size %v [byte] (codec: %v)", dist.Min(), minSize, codec.Name())
+		}
+		// 符号化した長さは値によらないため、指定したサイズの範囲の両端で一度だけ確かめる
+		for _, size := range []int{dist.Min(), dist.Max()} {
+			if n := len(codec.Encode(sample, size)); n != size {
+				log.Fatalf("Message size error: encoded %v [byte] for %v [byte] (codec: %v)", n, size, codec.Name())
+			}
+		}
+		// padding は必要な長さに満たない場合は繰り返して使われる
+		paddingLen := dist.Max()
+		if paddingLen > 1<<16 {
+			paddingLen = 1 << 16
+		}
+		padding := randString1(paddingLen)
+		if script != nil {
+			// 記録と同じ padding を使い、メッセージの内容を一致させる
+			padding = script.Padding
+			for _, es := range script.Entries {
+				for _, e := range es {
+					if e.Phase < 0 || e.Phase >= payload.Phases {
+						log.Fatalf("Replay error: invalid phase %v (seq: %v)", e.Phase, e.Seq)
+					}
+					if e.Size < minSize {
+						log.Fatalf("Replay error: %v [byte] is smaller than the minimum payload size %v [byte] (seq: %v, codec: %v)", e.Size, minSize, e.Seq, codec.Name())
+					}
+				}
+			}
+			log.Printf("Replay schedule : id=%v codec=%v goroutines=%v publishes=%v warmup=%v [ms] measure=%v [ms] rate=%v [pub/s]",
+				script.ID, script.Codec, script.Goroutines, script.N(), script.WarmUpMs, script.MeasureMs, script.Rate)
+		}
+
+		arrivalDist, err := arrival.Parse(*arrivalSpec)
+		if err != nil {
+			log.Fatalf("Inter-arrival error: %s", err)
+		}
+		var burstStart time.Time
+		if *burst > 0 {
+			if *bursts < 1 {
+				log.Fatalf("Burst error: -bursts must be at least 1")
+			}
+			if *sweepDepth != "" {
+				log.Fatalf("Burst error: burst mode cannot be combined with -sweepDepth")
+			}
+			if script != nil {
+				log.Fatalf("Burst error: burst mode cannot be combined with -replay")
+			}
+			if *burstAt != "" {
+				if burstStart, err = parseTime(*burstAt); err != nil {
+					log.Fatalf("Burst error: %s", err)
+				}
+			}
+		}
+
+		scheme, err := topic.NewScheme(*schemeName, *topicLevel, *topicDepth, *topicSep)
+		if err != nil {
+			log.Fatalf("Topic scheme error: %s", err)
+		}
+		area, err := scheme.Area(*prefix)
+		if err != nil {
+			log.Fatalf("Topic name translation error: %s", err)
+		}
+		depths := []int{scheme.Layout().Depth}
+		if *sweepDepth != "" {
+			if *backendName != "single" {
+				log.Fatalf("Topic depth sweep is only available with the single backend.")
+			}
+			if depths, err = parseDepths(*sweepDepth); err != nil {
+				log.Fatalf("Topic depth error: %s", err)
+			}
+		}
+
+		if len(traces) > 0 {
+			points, end := 0, time.Duration(0)
+			for _, tr := range traces {
+				points += len(tr.Points)
+				if tr.End() > end {
+					end = tr.End()
+				}
+			}
+			log.Printf("Trace summary : traces=%v points=%v duration=%v replayed=%v (x%v)", len(traces), points, end, time.Duration(float64(end) / *traceSpeed), *traceSpeed)
+		}
+		if *sweepDepth != "" && (*recordPath != "" || script != nil) {
+			log.Fatalf("Topic depth sweep cannot be combined with -record or -replay.")
+		}
+
+		var events *runlog.Writer
+		if *eventLog != "" {
+			events, err = runlog.Create(*eventLog)
+			if err != nil {
+				log.Fatalf("Event log error: %s", err)
+			}
+		}
+		defer func() {
+			if err := events.Close(); err != nil {
+				log.Printf("Event log error: %s", err)
+			}
+		}()
+
+		signalCh := make(chan os.Signal, 1)
+		signal.Notify(signalCh, os.Interrupt, os.Kill)
+		seq := int64(0)
+
+		// measure は scheme のトピックへ Publish する計測を 1 回行い、Publish 数と中断されたかを返す
+		measure := func(scheme topic.Scheme, id string) (int64, bool) {
+			latlng := area.Locate(uint64(time.Now().UnixNano()), *locLevel)
+			log.Print(scheme.Topic(latlng))
+			opts := backend.Options{Host: *host, Port: *port, Lat: latlng.Lat.Degrees(), Lng: latlng.Lng.Degrees(), Scheme: scheme, QoS: byte(*qos)}
+			clients := make([]backend.Client, *clientNum)
+			log.Print("Allocated!!!")
+			connectErrors := 0
+			for i := 0; i < *clientNum; i++ {
+				opts := opts
+				if traces != nil {
+					// 軌跡ごとのクライアントは軌跡の最初の位置で接続する
+					opts.Lat, opts.Lng = traces[i].At(0)
+				}
+				// ゲートウェイブローカへ接続
+				c, err := backend.ConnectRetry(*backendName, opts, *retry, time.Second, func(err error) {
+					connectErrors++
+					log.Printf("MQTT Connect error: %s", err)
+				})
+				if err != nil {
+					log.Fatalf("MQTT Connect error: gave up after %v attempts", *retry+1)
+				}
+				log.Printf("Client counter: %v", i+1)
+				clients[i] = c
+			}
+
+			// 計測の段階ごとに分けて記録し、集計には PhaseMeasure の段階のみを使う
+			recorders := timeseries.NewRecorders(payload.Phases, time.Second, *bufferSec)
+			recorder := recorders.Get(payload.PhaseMeasure)
+			// Publish の呼び出しから完了（QoS 1, 2 では PUBACK, PUBCOMP の受信）までの時間[us]
+			ackRecorders := timeseries.NewRecorders(payload.Phases, time.Second, *bufferSec)
+			acks := histogram.New()
+			// 実際に Publish した間隔[us]（Gorutine ごとに、計測の段階のみ）
+			gaps := histogram.New()
+			stopCh := make(chan struct{})
+			var sched schedule
+			var publishErrors int64
+			var stopOnce sync.Once
+			stop := func() {
+				stopOnce.Do(func() {
+					close(stopCh)
+					// 送信中の goroutine が抜けるのを待つ
+					time.Sleep(time.Millisecond * 100)
+					recorders.CloseAll()
+					ackRecorders.CloseAll()
+					printRates(recorders.Flush())
+					printAcks(ackRecorders.Flush())
+					printTotal(recorders, id, atomic.LoadInt64(&publishErrors))
+					printAckHistogram(acks, id)
+					printInterArrival(gaps, id)
+				})
+			}
+			defer func() {
+				stop()
+				// Subscriber 側で受信率や Publish の頻度を判定できるよう、計測の段階の Publish 数と時間を伝える
+				now := time.Now()
+				msg := fmt.Sprintf("{\"id\":\"%v\",\"time_ms\":%v,\"is_done\":true,\"published\":%v,\"rate\":%v,\"measure_ms\":%v,\"connect_errors\":%v}",
+					id, now.UnixNano()/int64(time.Millisecond), recorder.Total(id).N, *rate, sched.measured(now).Nanoseconds()/int64(time.Millisecond), connectErrors)
+				if err := clients[0].PublishSignal([]byte(msg)); err != nil {
+					if _, ok := err.(backend.UnsupportedError); !ok {
+						log.Printf("MQTT Publish error (done signal): %s", err)
+					}
+				}
+				for i, c := range clients {
+					c.Disconnect()
+					log.Printf("Disconnecting... (%v)", i)
+				}
+			}()
+
+			sched = schedule{start: time.Now(), warmUp: time.Duration(*warmUp) * time.Second, measure: time.Duration(*t) * time.Second}
+			if script != nil {
+				// 記録の全ての予定を Publish し終えた時点で終了する
+				sched = schedule{start: sched.start, warmUp: time.Duration(script.WarmUpMs) * time.Millisecond, measure: time.Duration(script.MeasureMs) * time.Millisecond}
+			}
+			if *burst > 0 {
+				// バースト送信は全て集計の対象とし、全ての Gorutine が送信し終えた時点で終了する
+				sched = schedule{start: sched.start, measure: time.Duration(math.MaxInt64)}
+				if burstStart.IsZero() {
+					burstStart = time.Now().Add(time.Second)
+				}
+				if time.Until(burstStart) < 0 {
+					log.Printf("[Warning] The first burst time has already passed: %v", burstStart.Format(time.RFC3339Nano))
+				}
+				log.Printf("Burst schedule : first=%v count=%v every=%v [sec] size=%v [pub/goroutine]", burstStart.Format(time.RFC3339Nano), *bursts, *burstEvery, *burst)
+			}
+			// 計測の段階の終了後は、計測の段階の Publish 数を 0 件の区間として表示しない
+			measureEnd := sched.start.Add(sched.warmUp + sched.measure)
+			recorders.SetEnd(payload.PhaseMeasure, measureEnd)
+			ackRecorders.SetEnd(payload.PhaseMeasure, measureEnd)
+			var record *replay.Writer
+			if *recordPath != "" {
+				h := replay.Header{ID: id, Codec: codec.Name(), Padding: padding, Goroutines: *rutines, WarmUpMs: sched.warmUp.Nanoseconds() / int64(time.Millisecond), MeasureMs: sched.measure.Nanoseconds() / int64(time.Millisecond), Rate: *rate}
+				if record, err = replay.Create(*recordPath, h); err != nil {
+					log.Fatalf("Record error: %s", err)
+				}
+				defer func() {
+					if err := record.Close(); err != nil {
+						log.Printf("Record error: %s", err)
+					}
+				}()
+			}
+			var wg sync.WaitGroup
+			finishedCh := make(chan struct{})
+			finished := func(st int64) bool {
+				// 全ての Gorutine が Publish し終えた（バースト送信や再現の完了、全ての軌跡の終了）場合は計測時間によらず終了する
+				if isStopped(finishedCh) {
+					return true
+				}
+				if *burst > 0 || script != nil {
+					return false
+				}
+				return time.Now().Unix()-st >= int64(*warmUp+*t+*coolDown)
+			}
+			doneCh := make(chan bool)
+			go func() {
+				for st := time.Now().Unix(); !finished(st); {
+					now := time.Now()
+					printRates(recorders.Collect(now))
+					printAcks(ackRecorders.Collect(now))
+					time.Sleep(time.Millisecond * 300)
+				}
+				stop()
+				doneCh <- true
+			}()
+
+			if *resourceSec > 0 {
+				stopSampler := resource.Start(time.Duration(*resourceSec)*time.Second, func(r resource.Sample) {
+					printResource(r, *cpuLimit, *gcLimit)
+				})
+				defer stopSampler()
+			}
+			log.Print("Starting goroutine...")
+			time.Sleep(time.Millisecond * 100)
+			for i := 0; i < *rutines; i++ {
+				rng := rand.New(rand.NewSource(*seed + int64(i)))
+				p := newPacer(*interval, *rate, *rutines, i, arrivalDist.NewSampler(rand.New(rand.NewSource(arrival.Seed(*seed, i)))))
+				switch {
+				case *burst > 0:
+					p = newBurstPacer(burstStart, *burst, *bursts, time.Duration(*burstEvery)*time.Second)
+				case script != nil:
+					p = newReplayPacer(sched.start, script.Entries[i])
+				}
+				w := &worker{
+					c: clients[i%*clientNum], traceSpeed: *traceSpeed, index: i, sched: sched, writers: recorders.Writers(), ackWriters: ackRecorders.Writers(), acks: acks, gaps: gaps, stopCh: stopCh,
+					pacer: p, codec: codec, dist: dist, rng: rng, seq: &seq, errors: &publishErrors, events: events, record: record, pid: id, padding: padding, area: area, level: *locLevel,
+				}
+				if traces != nil {
+					w.trace = traces[i]
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.run()
+				}()
+			}
+			log.Print("Done launching goroutine.")
+			go func() {
+				wg.Wait()
+				close(finishedCh)
+			}()
+
+			//////////////           勝手に終了しないようにする          //////////////
+			for {
+				select {
+				case <-signalCh:
+					log.Print("Interrupt detected.")
+					stop()
+					return recorder.Total(id).N, true
+				case <-doneCh:
+					log.Print("Finished measurement.")
+					return recorder.Total(id).N, false
+				}
+			}
+		}
+
+		results := []string{}
+		for _, depth := range depths {
+			l := scheme.Layout()
+			s, err := topic.NewScheme(scheme.Name(), l.Level, depth, l.Separator)
+			if err != nil {
+				log.Fatalf("Topic scheme error: %s", err)
+			}
+			id := *pid
+			if *sweepDepth != "" {
+				// Subscriber 側で階層数ごとに集計できるよう、ID を分ける
+				id = fmt.Sprintf("%v-d%v", *pid, depth)
+			}
+			topicLen := len(s.Topic(area.Locate(0, topic.MaxLevel)))
+			if *backendName == "single" {
+				log.Printf("Topic layout : %v %v [topic=%v byte] (ID: %v)", s.Name(), s.Layout(), topicLen, id)
+			}
+			n, interrupted := measure(s, id)
+			results = append(results, fmt.Sprintf("Depth sweep : depth=%v topic=%v [byte] total=%v [pub] rate=%v [pub/s] (ID: %v)", depth, topicLen, n, float64(n)/float64(*t), id))
+			if interrupted {
+				break
+			}
+			if len(depths) > 1 {
+				time.Sleep(time.Second)
+			}
+		}
+		if *sweepDepth != "" {
+			for _, r := range results {
+				log.Print(r)
+			}
+		}
+	}
+}
+
+// worker は 1 つの Gorutine で Publish を繰り返す
+type worker struct {
+	c          backend.Client
+	index      int
+	sched      schedule
+	writers    []*timeseries.Writer
+	ackWriters []*timeseries.Writer
+	acks, gaps *histogram.Histogram
+	stopCh     <-chan struct{}
+	pacer      *pacer
+	codec      payload.Codec
+	dist       payload.SizeDist
+	rng        *rand.Rand
+	seq        *int64
+	// errors は Publish に失敗した数（全ての Gorutine で共有）
+	errors *int64
+	events *runlog.Writer
+	// trace は Publish する位置を決める軌跡（軌跡を使わない場合は nil）で、計測の開始からの時間の traceSpeed 倍の時点の位置から Publish する
+	trace      *trace.Trace
+	traceSpeed float64
+	// record は Publish の予定の記録先（記録しない場合は nil）
+	record       *replay.Writer
+	pid, padding string
+	area         topic.Area
+	level        int
+}
+
+func (w *worker) run() {
+	p := w.pacer
+	if !p.start() {
+		return
+	}
+	var prev time.Time
+	for {
+		now := time.Now().UnixNano()
+		if isStopped(w.stopCh) {
+			break
+		}
+		var d time.Duration
+		if w.trace != nil {
+			d = time.Duration(float64(time.Unix(0, now).Sub(w.sched.start)) * w.traceSpeed)
+			if d > w.trace.End() {
+				return
+			}
+			if d < w.trace.Start() {
+				if !p.wait() {
+					return
+				}
+				continue
+			}
+		}
+		msg := payload.Measurement{ID: w.pid, TimeMs: now / int64(time.Millisecond), BurstMs: p.burstMs(), Padding: w.padding}
+		size := 0
+		if e := p.replayed(); e != nil {
+			msg.Seq, msg.Lat, msg.Lng, msg.Phase, size = e.Seq, e.Lat, e.Lng, e.Phase, e.Size
+		} else {
+			if w.trace != nil {
+				msg.Lat, msg.Lng = w.trace.At(d)
+			} else {
+				latlng := w.area.Locate(uint64(now), w.level)
+				msg.Lat, msg.Lng = latlng.Lat.Degrees(), latlng.Lng.Degrees()
+			}
+			msg.Seq, msg.Phase, size = atomic.AddInt64(w.seq, 1), w.sched.phase(time.Unix(0, now)), w.dist.Next(w.rng)
+		}
+		phase := msg.Phase
+		b := w.codec.Encode(msg, size)
+		// 失敗した Publish も再現できるよう、Publish の前に予定を記録する
+		if err := w.record.Write(w.entry(msg, now, size)); err != nil {
+			log.Printf("Record error: %s", err)
+		}
+		// 位置からトピック名への変換を含む Publish の呼び出し全体の時間を計る
+		st := time.Now()
+		if !prev.IsZero() && phase == payload.PhaseMeasure {
+			w.gaps.Add(st.Sub(prev).Nanoseconds() / int64(time.Microsecond))
+		}
+		prev = st
+		if err := w.c.Publish(msg.Lat, msg.Lng, b); err != nil {
+			// 失敗したメッセージは Publish 数に含めず、件数を集計の最後に表示する
+			atomic.AddInt64(w.errors, 1)
+			log.Printf("MQTT Publish error: %s", err)
+			if !p.wait() {
+				return
+			}
+			continue
+		}
+		ack := time.Since(st).Nanoseconds() / int64(time.Microsecond)
+		w.ackWriters[phase].Add(w.pid, st, ack)
+		if phase == payload.PhaseMeasure {
+			w.acks.Add(ack)
+		}
+		if err := w.events.Write(runlog.Record{Type: runlog.TypePublish, ID: w.pid, Seq: msg.Seq, TimeMs: msg.TimeMs, Lat: msg.Lat, Lng: msg.Lng, Phase: phase}); err != nil {
+			log.Printf("Event log error: %s", err)
+		}
+		w.writers[phase].Add(w.pid, time.Now(), 1)
+		if !p.wait() {
+			return
+		}
+	}
+}
+
+// entry は時刻 now[ns] に Publish する msg を、計測の開始からの時刻で表した Publish の予定にする
+func (w *worker) entry(msg payload.Measurement, now int64, size int) replay.Entry {
+	start := w.sched.start.UnixNano()
+	e := replay.Entry{OffsetUs: (now - start) / int64(time.Microsecond), Goroutine: w.index, Seq: msg.Seq, Lat: msg.Lat, Lng: msg.Lng, Size: size, Phase: msg.Phase}
+	if msg.BurstMs != 0 {
+		e.Burst, e.BurstOffsetUs = true, (msg.BurstMs*int64(time.Millisecond)-start)/int64(time.Microsecond)
+	}
+	return e
+}
+
+// pacer は Publish の間隔を決める
+type pacer struct {
+	// period は平均の間隔で、実際の間隔は sampler が period を平均とする分布から選ぶ
+	period  time.Duration
+	sampler arrival.Sampler
+	// scheduled が true の場合は Publish にかかった時間によらず予定の時刻に Publish し、false の場合は Publish した後に間隔の分だけ sleep する
+	scheduled bool
+	next      time.Time
+	// burst が 0 より大きい場合は、next から burstEvery おきに burst 件ずつ続けて Publish し、これを bursts 回繰り返す
+	burst      int
+	bursts     int
+	burstEvery time.Duration
+	sent       int
+	// replaying が true の場合は、再現する Publish の予定 script を base からの時刻に順に Publish する
+	replaying bool
+	script    []replay.Entry
+	base      time.Time
+}
+
+// newPacer は n 個の Gorutine のうち i 番目の Gorutine の pacer を生成する
+// rate が 0 より大きい場合は、全体で rate[pub/s] となるよう各 Gorutine の Publish する時刻をずらして予定する
+func newPacer(intervalMs int, rate float64, n, i int, sampler arrival.Sampler) *pacer {
+	if rate <= 0 {
+		return &pacer{period: time.Duration(intervalMs) * time.Millisecond, sampler: sampler}
+	}
+	period := time.Duration(float64(time.Second) * float64(n) / rate)
+	return &pacer{period: period, sampler: sampler, scheduled: true, next: time.Now().Add(period * time.Duration(i) / time.Duration(n))}
+}
+
+// newBurstPacer は first から every おきに size 件ずつ続けて Publish することを count 回繰り返す pacer を生成する
+func newBurstPacer(first time.Time, size, count int, every time.Duration) *pacer {
+	return &pacer{next: first, burst: size, bursts: count, burstEvery: every}
+}
+
+// newReplayPacer は base を計測の開始として script の予定の時刻に Publish する pacer を生成する
+func newReplayPacer(base time.Time, script []replay.Entry) *pacer {
+	p := &pacer{replaying: true, script: script, base: base}
+	if len(script) > 0 {
+		p.next = base.Add(time.Duration(script[0].OffsetUs) * time.Microsecond)
+	}
+	return p
+}
+
+// start は最初に Publish する時刻まで待ち、Publish する予定が無い場合は false を返す
+func (p *pacer) start() bool {
+	if p.replaying && len(p.script) == 0 {
+		return false
+	}
+	if p.scheduled || p.burst > 0 || p.replaying {
+		time.Sleep(time.Until(p.next))
+	}
+	return true
+}
+
+// wait は次に Publish する時刻まで待ち、全て Publish し終えた場合は false を返す
+// scheduled の場合、予定より遅れていても次の Publish を省かない（遅れは頻度の不足として結果に表れる）
+func (p *pacer) wait() bool {
+	switch {
+	case p.replaying:
+		if p.sent++; p.sent >= len(p.script) {
+			return false
+		}
+		p.next = p.base.Add(time.Duration(p.script[p.sent].OffsetUs) * time.Microsecond)
+	case p.burst > 0:
+		if p.sent++; p.sent < p.burst {
+			return true
+		}
+		p.sent = 0
+		if p.bursts--; p.bursts <= 0 {
+			return false
+		}
+		p.next = p.next.Add(p.burstEvery)
+	case p.scheduled:
+		p.next = p.next.Add(p.sampler.Next(p.period))
+	default:
+		time.Sleep(p.sampler.Next(p.period))
+		return true
+	}
+	if d := time.Until(p.next); d > 0 {
+		time.Sleep(d)
+	}
+	return true
+}
+
+// burstMs はバースト送信の場合に現在のバーストの予定時刻[ms]を返す（それ以外は 0）
+func (p *pacer) burstMs() int64 {
+	if e := p.replayed(); e != nil {
+		if !e.Burst {
+			return 0
+		}
+		return p.base.Add(time.Duration(e.BurstOffsetUs)*time.Microsecond).UnixNano() / int64(time.Millisecond)
+	}
+	if p.burst <= 0 {
+		return 0
+	}
+	return p.next.UnixNano() / int64(time.Millisecond)
+}
+
+// replayed は再現する場合に次に Publish する予定を返す（それ以外は nil）
+func (p *pacer) replayed() *replay.Entry {
+	if !p.replaying || p.sent >= len(p.script) {
+		return nil
+	}
+	return &p.script[p.sent]
+}
+
+// schedule は計測の段階の区切り
+type schedule struct {
+	start   time.Time
+	warmUp  time.Duration
+	measure time.Duration
+}
+
+// phase は時刻 t の段階を返す
+func (s schedule) phase(t time.Time) int {
+	switch elapsed := t.Sub(s.start); {
+	case elapsed < s.warmUp:
+		return payload.PhaseWarmUp
+	case elapsed < s.warmUp+s.measure:
+		return payload.PhaseMeasure
+	}
+	return payload.PhaseCoolDown
+}
+
+// measured は時刻 t までに PhaseMeasure の段階が続いた時間を返す
+func (s schedule) measured(t time.Time) time.Duration {
+	d := t.Sub(s.start) - s.warmUp
+	switch {
+	case s.start.IsZero() || d < 0:
+		return 0
+	case d > s.measure:
+		return s.measure
+	}
+	return d
+}
+
+func isStopped(stopCh <-chan struct{}) bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// printRates は段階ごとの Publish 数を表示する
+// 集計から除く段階は、計測スクリプトの集計に含まれない形式で、値のある区間のみ表示する
+func printRates(points [][]timeseries.Point) {
+	for phase, ps := range points {
+		for _, p := range ps {
+			if phase == payload.PhaseMeasure {
+				log.Printf("Publish rate: %v [pub/s]", p.N)
+			} else if p.N > 0 {
+				log.Printf("Publish rate (%v): %v [pub/s] (ID: %v)", payload.PhaseName(phase), p.N, p.ID)
+			}
+		}
+	}
+}
+
+func printAcks(points [][]timeseries.Point) {
+	for phase, ps := range points {
+		for _, p := range ps {
+			if phase == payload.PhaseMeasure {
+				log.Printf("Ack latency : %v [us] [n=%v] [min=%v] [max=%v] (ID: %v)", p.Mean(), p.N, p.Min, p.Max, p.ID)
+			} else if p.N > 0 {
+				log.Printf("Ack latency (%v) : %v [us] [n=%v] [min=%v] [max=%v] (ID: %v)", payload.PhaseName(phase), p.Mean(), p.N, p.Min, p.Max, p.ID)
+			}
+		}
+	}
+}
+
+func printAckHistogram(acks *histogram.Histogram, pid string) {
+	log.Printf("Ack latency total : %v [us] [n=%v] [min=%v] [max=%v] [p50=%v] [p90=%v] [p99=%v] [p99.9=%v] (ID: %v)",
+		acks.Mean(), acks.N(), acks.Min(), acks.Max(), acks.Quantile(0.5), acks.Quantile(0.9), acks.Quantile(0.99), acks.Quantile(0.999), pid)
+	for _, b := range acks.Octaves() {
+		log.Printf("Ack latency histogram : [%v, %v) [us] [n=%v] (ID: %v)", b.Lo, b.Hi, b.N, pid)
+	}
+}
+
+// printInterArrival は実際に Publish した間隔の分布を表示する
+// cv は変動係数（標準偏差 / 平均）で、fixed では 0、exp では 1 に近くなる
+func printInterArrival(gaps *histogram.Histogram, pid string) {
+	cv := 0.
+	if m := gaps.Mean(); m > 0 {
+		cv = gaps.Stddev() / m
+	}
+	log.Printf("Inter-arrival total : %v [us] [n=%v] [min=%v] [max=%v] [p50=%v] [p90=%v] [p99=%v] [cv=%.3f] (ID: %v)",
+		gaps.Mean(), gaps.N(), gaps.Min(), gaps.Max(), gaps.Quantile(0.5), gaps.Quantile(0.9), gaps.Quantile(0.99), cv, pid)
+	for _, b := range gaps.Octaves() {
+		log.Printf("Inter-arrival histogram : [%v, %v) [us] [n=%v] (ID: %v)", b.Lo, b.Hi, b.N, pid)
+	}
+}
+
+func printTotal(recorders *timeseries.Recorders, pid string, errors int64) {
+	log.Printf("Publish total: %v [pub] (ID: %v)", recorders.Get(payload.PhaseMeasure).Total(pid).N, pid)
+	log.Printf("Publish errors: %v [pub] (ID: %v)", errors, pid)
+	for _, phase := range []int{payload.PhaseWarmUp, payload.PhaseCoolDown} {
+		if n := recorders.Get(phase).Total(pid).N; n > 0 {
+			log.Printf("Publish total (%v): %v [pub] (ID: %v)", payload.PhaseName(phase), n, pid)
+		}
+	}
+}
+
+func randString1(n int) string {
+	rs1Letters := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = rs1Letters[rand.Intn(len(rs1Letters))]
+	}
+	return string(b)
+}
+
+// parseTime は UNIX 時間[ms] または RFC3339 形式の時刻を解釈する
+func parseTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// parseDepths はカンマ区切りの階層数の一覧を解釈する
+func parseDepths(s string) ([]int, error) {
+	depths := []int{}
+	for _, v := range strings.Split(s, ",") {
+		d, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		depths = append(depths, d)
+	}
+	return depths, nil
+}
+
+func printResource(r resource.Sample, cpuLimit, gcLimit float64) {
+	log.Printf("Resource : %v", r)
+	if reason := r.Bottleneck(cpuLimit, gcLimit); reason != "" {
+		log.Printf("[Warning] Load generator bottleneck : %v", reason)
+	}
+}
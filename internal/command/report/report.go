@@ -0,0 +1,48 @@
+package report
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/report"
+	"location-based-mqtt-evaluation-tool/internal/result"
+)
+
+// Command は pub と sub のログファイルから HTML のレポートを生成するサブコマンド
+var Command = cli.Command{
+	Name:    "report",
+	Summary: "ログファイルからグラフと表をまとめた HTML のレポートを生成する",
+	Define:  define,
+}
+
+func define(fs *flag.FlagSet) func() {
+	output := fs.String("o", "report.html", "出力する HTML ファイルのパス")
+	title := fs.String("title", "lbmqtt-eval report", "レポートのタイトル")
+	return func() {
+		if fs.NArg() == 0 {
+			log.Fatal("No log file is specified.")
+		}
+		runs := []*result.Run{}
+		for _, path := range fs.Args() {
+			r, err := result.Read(path)
+			if err != nil {
+				log.Fatalf("Log file error: %s", err)
+			}
+			runs = append(runs, r)
+		}
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Report error: %s", err)
+		}
+		if err := report.Write(f, *title, runs); err != nil {
+			f.Close()
+			log.Fatalf("Report error: %s", err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("Report error: %s", err)
+		}
+		log.Printf("Report : %v", *output)
+	}
+}
@@ -0,0 +1,41 @@
+package seal
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/provenance"
+)
+
+// Command は結果ファイルのハッシュを台帳に追記するサブコマンド
+var Command = cli.Command{
+	Name:    "seal",
+	Summary: "結果ファイルのハッシュを台帳に追記する",
+	Define:  define,
+}
+
+// DefaultLedger は台帳の既定のパス
+func DefaultLedger() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("logs/%v.ledger", hostname)
+}
+
+func define(fs *flag.FlagSet) func() {
+	ledger := fs.String("ledger", DefaultLedger(), "台帳のパス")
+	return func() {
+		if fs.NArg() == 0 {
+			log.Fatal("No file to seal.")
+		}
+		e, err := provenance.Seal(*ledger, fs.Args())
+		if err != nil {
+			log.Fatalf("Ledger error: %s", err)
+		}
+		for _, f := range e.Files {
+			log.Printf("Sealed : %v %v (Seq: %v)", f.SHA256, f.Path, e.Seq)
+		}
+		log.Printf("Ledger head : seq=%v hash=%v (%v)", e.Seq, e.Hash, *ledger)
+	}
+}
@@ -0,0 +1,318 @@
+package search
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"location-based-mqtt-evaluation-tool/internal/backend"
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/proc"
+)
+
+// Command は Publish の頻度を変えながら計測を繰り返し、遅延と欠損の目標を満たす最大の頻度を探すサブコマンド
+var Command = cli.Command{
+	Name:    "search",
+	Summary: "遅延と欠損の目標を満たす最大の Publish 頻度を探す",
+	Define:  define,
+	Values:  map[string][]string{"backend": backend.Names()},
+}
+
+var (
+	averageRe      = regexp.MustCompile(`Average : ([0-9.eE+-]+) \[ms\] \[n=([0-9]+)\] \(ID: (.+)\)$`)
+	latencyTotalRe = regexp.MustCompile(`Latency total : [0-9.eE+-]+ \[ms\] \[n=([0-9]+)\] .*\[p99=(-?[0-9]+)\] .*\(ID: (.+)\)$`)
+	publishTotalRe = regexp.MustCompile(`Publish total: ([0-9]+) \[pub\] \(ID: (.+)\)$`)
+	clientNumRe    = regexp.MustCompile(`OPTION Client num\s+: ([0-9]+)$`)
+)
+
+// step は 1 つの頻度での計測の結果
+type step struct {
+	Rate     float64
+	Achieved float64
+	P99      float64
+	Loss     float64
+	Aborted  bool
+	// SubFailed は sub が判定条件（-sub で渡した -assert など）を満たさずに終了コード 1 で終了したことを表す
+	SubFailed bool
+	Pass      bool
+}
+
+func (s step) result() string {
+	switch {
+	case s.Aborted:
+		return "aborted"
+	case s.Pass:
+		return "pass"
+	}
+	return "fail"
+}
+
+func (s step) String() string {
+	return fmt.Sprintf("rate=%v [pub/s] achieved=%.1f [pub/s] p99=%v [ms] loss=%.4f result=%v", s.Rate, s.Achieved, s.P99, s.Loss, s.result())
+}
+
+func define(fs *flag.FlagSet) func() {
+	backendName := fs.String("backend", "single", fmt.Sprintf("接続するブローカの種類 (%v)", strings.Join(backend.Names(), ", ")))
+	host := fs.String("host", "127.0.0.1", "ブローカーホスト名")
+	port := fs.Int("port", 1883, "ブローカーポート番号")
+	pubArgs := fs.String("pub", "", "pub に渡す追加のフラグ（空白区切り）")
+	subArgs := fs.String("sub", "", "sub に渡す追加のフラグ（空白区切り）。欠損率を求めるため、-subR は 0（全てのメッセージを受信する）に固定する")
+	delay := fs.Int("delay", 2, "sub を起動してから pub を起動するまでの秒数")
+	waitSec := fs.Int("waitsec", 5, "sub に渡す -waitsec")
+	t := fs.Int("time", 30, "1 つの頻度での計測時間[sec]")
+	warmUp := fs.Int("warmup", 5, "1 つの頻度での計測前に Publish する時間[sec]（集計から除く）")
+	startRate := fs.Float64("start", 100, "最初に計測する Publish の頻度[pub/s]")
+	stepRate := fs.Float64("step", 100, "目標を満たす間、頻度を増やす幅[pub/s]")
+	maxRate := fs.Float64("max", 10000, "計測する Publish の頻度の上限[pub/s]")
+	resolution := fs.Float64("resolution", 10, "二分探索を終える頻度の幅[pub/s]")
+	p99 := fs.Float64("p99", 100, "遅延の 99 パーセンタイルの目標[ms]")
+	maxLoss := fs.Float64("maxLoss", 0.001, "欠損率の目標（0 から 1）")
+	minAchieved := fs.Float64("minAchieved", 0.95, "指定した頻度に対して実際に Publish できた頻度の割合の下限（下回った場合は負荷生成側が追いついていないとみなし、目標を満たさないとする）")
+	abortSec := fs.Int("abort", 5, "1 秒ごとの平均遅延が -p99 を連続して超えた場合に、その頻度での計測を打ち切る秒数（0 の場合は打ち切らない）")
+	logDir := fs.String("logdir", "", "頻度ごとの pub と sub の出力を書き出すディレクトリ（省略時は標準出力のみ）")
+	return func() {
+		log.Print("Starting...")
+
+		// オプションの表示
+		log.Printf("OPTION Backend                    : %v", *backendName)
+		log.Printf("OPTION Broker hostname            : %v", *host)
+		log.Printf("OPTION Broker port                : %v", *port)
+		log.Printf("OPTION Publisher args             : %v", *pubArgs)
+		log.Printf("OPTION Subscriber args            : %v", *subArgs)
+		log.Printf("OPTION Publisher delay            : %v [sec]", *delay)
+		log.Printf("OPTION Wait time                  : %v [sec]", *waitSec)
+		log.Printf("OPTION Measurement time           : %v [s]", *t)
+		log.Printf("OPTION Warm-up time               : %v [s]", *warmUp)
+		log.Printf("OPTION Start rate                 : %v [pub/s]", *startRate)
+		log.Printf("OPTION Rate step                  : %v [pub/s]", *stepRate)
+		log.Printf("OPTION Max rate                   : %v [pub/s]", *maxRate)
+		log.Printf("OPTION Rate resolution            : %v [pub/s]", *resolution)
+		log.Printf("OPTION p99 latency target         : %v [ms]", *p99)
+		log.Printf("OPTION Loss ratio target          : %v", *maxLoss)
+		log.Printf("OPTION Min achieved ratio         : %v", *minAchieved)
+		log.Printf("OPTION Abort after                : %v [sec]", *abortSec)
+		log.Printf("OPTION Log directory              : %v", *logDir)
+
+		if *startRate <= 0 || *stepRate <= 0 || *resolution <= 0 {
+			log.Fatalf("Invalid rate: start, step and resolution must be positive.")
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatalf("Executable error: %s", err)
+		}
+		if *logDir != "" {
+			if err := os.MkdirAll(*logDir, 0755); err != nil {
+				log.Fatalf("Log directory error: %s", err)
+			}
+		}
+
+		r := &runner{
+			exe:       exe,
+			common:    []string{"-backend", *backendName, "-host", *host, "-port", strconv.Itoa(*port)},
+			pubArgs:   strings.Fields(*pubArgs),
+			subArgs:   append(strings.Fields(*subArgs), "-waitsec", strconv.Itoa(*waitSec), "-subR", "0"),
+			delay:     time.Duration(*delay) * time.Second,
+			t:         *t,
+			warmUp:    *warmUp,
+			p99:       *p99,
+			maxLoss:   *maxLoss,
+			achieved:  *minAchieved,
+			abortSec:  *abortSec,
+			logDir:    *logDir,
+			interrupt: make(chan os.Signal, 1),
+		}
+		signal.Notify(r.interrupt, os.Interrupt)
+
+		// 目標を満たさなくなるまで頻度を -step ずつ増やし、その後は満たした頻度と満たさなかった頻度の間を二分探索する
+		steps := []step{}
+		run := func(rate float64) (step, bool) {
+			s, interrupted := r.run(len(steps), rate)
+			log.Printf("Search step : %v", s)
+			steps = append(steps, s)
+			return s, interrupted
+		}
+		lo, hi := 0., math.Inf(1)
+		interrupted := false
+		for rate := *startRate; rate <= *maxRate && !interrupted; rate += *stepRate {
+			var s step
+			if s, interrupted = run(rate); !s.Pass {
+				hi = rate
+				break
+			}
+			lo = rate
+		}
+		for !interrupted && !math.IsInf(hi, 1) && hi-lo > *resolution {
+			rate := math.Round((lo + hi) / 2)
+			if rate <= lo || rate >= hi {
+				break
+			}
+			var s step
+			if s, interrupted = run(rate); s.Pass {
+				lo = rate
+			} else {
+				hi = rate
+			}
+		}
+
+		sort.SliceStable(steps, func(i, j int) bool { return steps[i].Rate < steps[j].Rate })
+		for _, s := range steps {
+			log.Printf("Search curve : %v", s)
+		}
+		if interrupted {
+			log.Print("Interrupt detected.")
+		}
+		if lo == 0 {
+			log.Printf("[Warning] No rate satisfied the target (p99 <= %v [ms], loss <= %v)", *p99, *maxLoss)
+			cli.SetExitCode(1)
+			return
+		}
+		if math.IsInf(hi, 1) {
+			log.Printf("[Warning] The target was satisfied up to the max rate. Increase -max to find the limit.")
+		}
+		log.Printf("Max sustainable rate : %v [pub/s] (p99 <= %v [ms], loss <= %v)", lo, *p99, *maxLoss)
+	}
+}
+
+// runner は 1 つの頻度での計測のために sub と pub を起動する
+type runner struct {
+	exe       string
+	common    []string
+	pubArgs   []string
+	subArgs   []string
+	delay     time.Duration
+	t         int
+	warmUp    int
+	p99       float64
+	maxLoss   float64
+	achieved  float64
+	abortSec  int
+	logDir    string
+	interrupt chan os.Signal
+}
+
+func (r *runner) logPath(i int, name string) string {
+	if r.logDir == "" {
+		return ""
+	}
+	return filepath.Join(r.logDir, fmt.Sprintf("step%02d-%v.log", i, name))
+}
+
+// run は i 番目の計測として rate[pub/s] で Publish し、その結果と中断されたかを返す
+// sub の 1 秒ごとの平均遅延が目標を連続して超えた場合は、pub を止めて計測を打ち切る
+func (r *runner) run(i int, rate float64) (step, bool) {
+	rateArg := strconv.FormatFloat(rate, 'f', -1, 64)
+	// ID は既定の -msglen に収まるよう、pub が生成する ID（10 文字）程度の長さにする
+	id := fmt.Sprintf("s%02d-r%v", i, rateArg)
+	s := step{Rate: rate, P99: math.NaN(), Loss: 1}
+	var (
+		mu          sync.Mutex
+		published   int64 = -1
+		received    int64
+		clients     int64 = 1
+		over        int
+		pubProcess  *os.Process
+		interrupted bool
+	)
+	abort := func() {
+		if pubProcess != nil && !s.Aborted {
+			log.Printf("[Warning] Average latency exceeded the target for %v seconds. Aborting rate %v [pub/s]...", over, rate)
+			s.Aborted = true
+			pubProcess.Signal(os.Interrupt)
+		}
+	}
+	onSub := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if m := clientNumRe.FindStringSubmatch(line); m != nil {
+			clients, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		if m := averageRe.FindStringSubmatch(line); m != nil && m[3] == id {
+			if v, _ := strconv.ParseFloat(m[1], 64); v > r.p99 {
+				over++
+			} else {
+				over = 0
+			}
+			if r.abortSec > 0 && over >= r.abortSec {
+				abort()
+			}
+		}
+		if m := latencyTotalRe.FindStringSubmatch(line); m != nil && m[3] == id {
+			received, _ = strconv.ParseInt(m[1], 10, 64)
+			s.P99, _ = strconv.ParseFloat(m[2], 64)
+		}
+	}
+	onPub := func(line string) {
+		if m := publishTotalRe.FindStringSubmatch(line); m != nil && m[2] == id {
+			mu.Lock()
+			published, _ = strconv.ParseInt(m[1], 10, 64)
+			mu.Unlock()
+		}
+	}
+
+	out := &proc.Output{}
+	sub, err := proc.Start(r.exe, "sub", append(append([]string{}, r.common...), r.subArgs...), out, r.logPath(i, "sub"), onSub)
+	if err != nil {
+		log.Fatalf("Subscriber start error: %s", err)
+	}
+	time.Sleep(r.delay)
+	args := append(append([]string{}, r.common...), r.pubArgs...)
+	args = append(args, "-pid", id, "-rate", rateArg, "-time", strconv.Itoa(r.t), "-warmup", strconv.Itoa(r.warmUp), "-cooldown", "0")
+	pub, err := proc.Start(r.exe, "pub", args, out, r.logPath(i, "pub"), onPub)
+	if err != nil {
+		sub.Process.Signal(os.Interrupt)
+		log.Fatalf("Publisher start error: %s", err)
+	}
+	mu.Lock()
+	pubProcess = pub.Process
+	mu.Unlock()
+
+	doneCh := make(chan struct{})
+	go func() {
+		select {
+		case <-r.interrupt:
+			// 中断は子プロセスへそのまま伝える
+			mu.Lock()
+			interrupted = true
+			mu.Unlock()
+			pub.Process.Signal(os.Interrupt)
+			sub.Process.Signal(os.Interrupt)
+		case <-doneCh:
+		}
+	}()
+	pubErr := pub.Wait()
+	subErr := sub.Wait()
+	out.Wait()
+	close(doneCh)
+	// sub の終了コード 1 は判定条件を満たさなかったことを表すため、その頻度の計測は失敗として扱う
+	if e, ok := subErr.(*exec.ExitError); ok && e.ExitCode() == 1 {
+		log.Printf("[Warning] Subscriber did not meet its conditions at rate=%v [pub/s]", rateArg)
+		s.SubFailed = true
+		subErr = nil
+	}
+	if pubErr != nil || subErr != nil {
+		log.Fatalf("Measurement failed (pub: %v, sub: %v)", pubErr, subErr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if published > 0 {
+		s.Achieved = float64(published) / float64(r.t)
+		// 受信数は全クライアントの合計のため、各クライアントが全てのメッセージを受信した場合の数と比べる
+		if clients > 0 {
+			s.Loss = 1 - float64(received)/float64(published*clients)
+		}
+	}
+	s.Pass = !s.Aborted && !s.SubFailed && !math.IsNaN(s.P99) && s.P99 <= r.p99 && s.Loss <= r.maxLoss && s.Achieved >= rate*r.achieved
+	return s, interrupted
+}
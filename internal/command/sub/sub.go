@@ -0,0 +1,787 @@
+package sub
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/backend"
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/geocheck"
+	"location-based-mqtt-evaluation-tool/internal/histogram"
+	"location-based-mqtt-evaluation-tool/internal/payload"
+	"location-based-mqtt-evaluation-tool/internal/resource"
+	"location-based-mqtt-evaluation-tool/internal/runlog"
+	"location-based-mqtt-evaluation-tool/internal/slo"
+	"location-based-mqtt-evaluation-tool/internal/timeseries"
+	"location-based-mqtt-evaluation-tool/internal/topic"
+	"location-based-mqtt-evaluation-tool/internal/trace"
+)
+
+// Command はメッセージを Subscribe して遅延を計測するサブコマンド
+var Command = cli.Command{
+	Name:    "sub",
+	Summary: "メッセージを Subscribe して遅延を計測する",
+	Define:  define,
+	Outputs: []string{"eventlog"},
+	Values:  map[string][]string{"backend": backend.Names(), "scheme": topic.Names()},
+}
+
+func define(fs *flag.FlagSet) func() {
+	backendName := fs.String("backend", "single", fmt.Sprintf("接続するブローカの種類 (%v)", strings.Join(backend.Names(), ", ")))
+	host := fs.String("host", "127.0.0.1", "ブローカーホスト名")
+	port := fs.Int("port", 1883, "ブローカーポート番号")
+	clientNum := fs.Int("clients", 1, "クライアント数")
+	waitSec := fs.Int("waitsec", 1, "Publisherからの終了シグナルを受信してから、実際にSubscribeを終了するまでの秒数")
+	bufferSec := fs.Int("buffer", 3600, "ID ごとに保持する計測結果の秒数")
+	sizeBuckets := fs.String("sizebuckets", "", "遅延を集計するメッセージサイズの区切り[byte]（カンマ区切り、省略時は 2 のべき乗）")
+	distBands := fs.String("distBands", "1,5,10,50,100,500,1000", "遅延を集計する Publisher と Subscriber（受信範囲の中心）の間の距離の区切り[km]（カンマ区切り）")
+	prefix := fs.String("prefix", "", "受信範囲の中心を選ぶ範囲を表す -scheme の方式のトピック名の接頭辞（省略時は s2 は /0、geohash は /s、grid は /90/180）")
+	locLevel := fs.Int("locLevel", topic.MaxLevel, "受信範囲の中心を選ぶ領域のレベル（方式ごとの最大値を超える場合は最大値）")
+	suscRadiusKm := fs.Float64("subR", 10., "メッセージ受信半径(Km)（0 以下の場合、single では全てのメッセージを、dmb では受信範囲の中心を含む 1 つのセルのメッセージを受信する）")
+	checkLevel := fs.Int("checkLevel", -1, "受信範囲の検査に使うセルのレベル（0 以上の場合は受信半径の円をこのレベルのセルで被覆した範囲で検査する。負の場合は -checkCircle に従う）")
+	checkCircle := fs.Bool("checkCircle", false, "受信範囲を受信半径の円そのもので検査する（省略時は、single では Subscribe したトピックフィルタにマッチする範囲、dmb では円で検査する）")
+	cellLevel := fs.Int("cellLevel", 10, "受信範囲外からのメッセージを集計するセルのレベル")
+	schemeName := fs.String("scheme", "s2", fmt.Sprintf("トピック名の方式 (%v)（single のみ）", strings.Join(topic.Names(), ", ")))
+	filterLevel := fs.Int("filterLevel", 10, "受信範囲をトピックフィルタに変換する際の位置の数（single のみ）")
+	topicLevel := fs.Int("topicLevel", -1, "トピック名で表す位置の数（負の場合は方式ごとの最大値）（single のみ）")
+	topicDepth := fs.Int("topicDepth", 0, "根を除いたトピックの階層数（0 の場合は -topicLevel と同じ）（single のみ）")
+	topicSep := fs.String("topicSep", "", "同じ階層に並べる位置同士の区切り文字（single のみ）")
+	eventLog := fs.String("eventlog", "", "受信範囲と受信したメッセージを記録するイベントログのパス（oracle で使用）")
+	resourceSec := fs.Int("resource", 1, "自身の資源使用量（CPU、RSS、goroutine 数、GC、ファイルディスクリプタ）を記録する間隔[sec]（0 の場合は記録しない）")
+	cpuLimit := fs.Float64("cpuLimit", 90, "自身がボトルネックになっているとみなす CPU 使用率[%]（GOMAXPROCS 個のコアに対する割合）")
+	gcLimit := fs.Float64("gcLimit", 10, "自身がボトルネックになっているとみなす GC による停止時間の割合[%]")
+	tracePath := fs.String("trace", "", "移動軌跡（GeoJSON の LineString、または id, timestamp, lat, lng の列を持つ CSV）のパス。軌跡ごとに 1 つのクライアントを割り当て（-clients の代わりに軌跡の数を使う）、受信範囲の中心を軌跡に沿って移動する。-prefix、-locLevel は使わない（dmb のみ）")
+	traceSpeed := fs.Float64("traceSpeed", 1, "軌跡の時間の圧縮率（例: 60 の場合は軌跡の 1 分を 1 秒で再生する）。軌跡の時刻は全ての軌跡のうち最も早い時刻を起動時に合わせるため、pub と同時に起動する")
+	traceUpdate := fs.Int("traceUpdate", 1000, "軌跡に沿って受信範囲を更新する間隔[ms]")
+	retry := fs.Int("retry", 0, "ブローカーへの接続に失敗した場合に 1 秒おきに再試行する回数")
+	qos := fs.Int("qos", 0, "計測用のメッセージとシグナルを Subscribe する際の QoS (0, 1, 2)（single のみ）")
+	assertions := &slo.Assertions{}
+	fs.Var(assertions, "assert", fmt.Sprintf("終了時に評価する判定条件（例: \"p99 < 50ms\"、\"delivery >= 99.9%%\"）。繰り返し指定可。満たさない場合、または計測値が無く評価できない場合は終了コード 1 で終了する（delivery は -subR 0 の場合のみ評価できる）（指標: %v）", strings.Join(slo.Metrics(), ", ")))
+	assertFile := fs.String("assertFile", "", "判定条件を 1 行に 1 つずつ書いたファイル（-assert に加える）")
+	allowSkip := fs.Bool("allowSkip", false, "計測値が無く評価できない判定条件を満たしたものとみなす（既定では満たさなかったものとみなす）")
+	return func() {
+		log.Print("Starting...")
+
+		var traces []*trace.Trace
+		if *tracePath != "" {
+			if *backendName != "dmb" {
+				log.Fatalf("Trace error: moving subscribe areas are only available with the dmb backend")
+			}
+			if *suscRadiusKm <= 0 || *traceSpeed <= 0 || *traceUpdate <= 0 {
+				log.Fatalf("Trace error: -subR, -traceSpeed and -traceUpdate must be positive")
+			}
+			var err error
+			if traces, err = trace.Load(*tracePath); err != nil {
+				log.Fatalf("Trace error: %s", err)
+			}
+			*clientNum = len(traces)
+		}
+
+		// オプションの表示
+		log.Printf("OPTION Backend                    : %v", *backendName)
+		log.Printf("OPTION Broker hostname            : %v", *host)
+		log.Printf("OPTION Broker port                : %v", *port)
+		log.Printf("OPTION Client num                 : %v", *clientNum)
+		log.Printf("OPTION Wait time                  : %v [sec]", *waitSec)
+		log.Printf("OPTION Buffer                     : %v [sec]", *bufferSec)
+		log.Printf("OPTION Size buckets               : %v", *sizeBuckets)
+		log.Printf("OPTION Distance bands             : %v [km]", *distBands)
+		log.Printf("OPTION Process prefix             : %v", *prefix)
+		log.Printf("OPTION Location level             : %v", *locLevel)
+		log.Printf("OPTION Subscribe area radius      : %v [KM]", *suscRadiusKm)
+		log.Printf("OPTION Area check level           : %v", *checkLevel)
+		log.Printf("OPTION Area check circle          : %v", *checkCircle)
+		log.Printf("OPTION Out-of-area cell level     : %v", *cellLevel)
+		log.Printf("OPTION Topic scheme               : %v", *schemeName)
+		log.Printf("OPTION Topic filter level         : %v", *filterLevel)
+		log.Printf("OPTION Topic level                : %v", *topicLevel)
+		log.Printf("OPTION Topic depth                : %v", *topicDepth)
+		log.Printf("OPTION Topic separator            : %v", *topicSep)
+		log.Printf("OPTION Event log                  : %v", *eventLog)
+		log.Printf("OPTION Resource interval          : %v [sec]", *resourceSec)
+		log.Printf("OPTION CPU limit                  : %v [%%]", *cpuLimit)
+		log.Printf("OPTION GC pause limit             : %v [%%]", *gcLimit)
+		log.Printf("OPTION Trace                      : %v", *tracePath)
+		log.Printf("OPTION Trace speed                : %v", *traceSpeed)
+		log.Printf("OPTION Trace update interval      : %v [ms]", *traceUpdate)
+		log.Printf("OPTION Connect retry              : %v", *retry)
+		log.Printf("OPTION QoS                        : %v", *qos)
+		log.Printf("OPTION Assertion file             : %v", *assertFile)
+		log.Printf("OPTION Allow skipped assertions   : %v", *allowSkip)
+
+		if *assertFile != "" {
+			if err := assertions.ReadFile(*assertFile); err != nil {
+				log.Fatalf("Assertion error: %s", err)
+			}
+		}
+		log.Printf("OPTION Assertions                 : %v", assertions)
+
+		if *qos < 0 || *qos > 2 {
+			log.Fatalf("Invalid QoS: %v", *qos)
+		}
+		scheme, err := topic.NewScheme(*schemeName, *topicLevel, *topicDepth, *topicSep)
+		if err != nil {
+			log.Fatalf("Topic scheme error: %s", err)
+		}
+		area, err := scheme.Area(*prefix)
+		if err != nil {
+			log.Fatalf("Topic name translation error: %s", err)
+		}
+
+		var events *runlog.Writer
+		if *eventLog != "" {
+			events, err = runlog.Create(*eventLog)
+			if err != nil {
+				log.Fatalf("Event log error: %s", err)
+			}
+		}
+
+		clients := make([]backend.Client, *clientNum)
+		log.Print("Allocated!!!")
+		latlng := randomLatLng(scheme, area, *locLevel)
+		opts := backend.Options{Host: *host, Port: *port, Lat: latlng.Lat.Degrees(), Lng: latlng.Lng.Degrees(), Scheme: scheme, FilterLevel: *filterLevel, QoS: byte(*qos)}
+		connectErrors := 0
+		for i := 0; i < *clientNum; i++ {
+			opts := opts
+			if traces != nil {
+				opts.Lat, opts.Lng = traces[i].At(0)
+			}
+			// ゲートウェイブローカへ接続
+			c, err := backend.ConnectRetry(*backendName, opts, *retry, time.Second, func(err error) {
+				connectErrors++
+				log.Printf("MQTT Connect error: %s", err)
+			})
+			if err != nil {
+				log.Fatalf("MQTT Connect error: gave up after %v attempts", *retry+1)
+			}
+			log.Printf("Client counter: %v", i+1)
+			clients[i] = c
+		}
+		defer func(clients []backend.Client) {
+			for i, c := range clients {
+				c.Disconnect()
+				log.Printf("Disconnecting... (%v)", i)
+			}
+			if err := events.Close(); err != nil {
+				log.Printf("Event log error: %s", err)
+			}
+		}(clients)
+
+		buckets, err := payload.ParseSizeBuckets(*sizeBuckets)
+		if err != nil {
+			log.Fatalf("Size bucket error: %s", err)
+		}
+		bands, err := parseBands(*distBands)
+		if err != nil {
+			log.Fatalf("Distance band error: %s", err)
+		}
+		// Publisher が付けた計測の段階ごとに分けて記録し、集計には PhaseMeasure の段階のみを使う
+		recorders := timeseries.NewRecorders(payload.Phases, time.Second, *bufferSec)
+		recorder := recorders.Get(payload.PhaseMeasure)
+		sizeRecorder := timeseries.NewRecorder(time.Second, *bufferSec)
+		latencies := newLatencyTotals(*clientNum)
+		distances := make([]*distanceTotals, *clientNum)
+		bursts := newBurstTotals(*clientNum)
+		// Publisher ごとの終了シグナル（Publish 数などを判定条件の評価に使う）
+		var signalMu sync.Mutex
+		signals := map[string]PayloadSignal{}
+		checkers := []*geocheck.Checker{}
+		if *resourceSec > 0 {
+			stopSampler := resource.Start(time.Duration(*resourceSec)*time.Second, func(r resource.Sample) {
+				printResource(r, *cpuLimit, *gcLimit)
+			})
+			defer stopSampler()
+		}
+		log.Print("Starting goroutine...")
+		traceStart := time.Now()
+		wholeWorld := *suscRadiusKm <= 0 && *backendName != "dmb"
+		// single ではブローカが配送する範囲（Subscribe したトピックフィルタ）で検査し、円より広い範囲からの正しい配送を範囲外としない
+		byFilter := *backendName == "single" && *checkLevel < 0 && !*checkCircle
+		checkArea := func(lat, lng float64) (*geocheck.Area, error) {
+			return newCheckArea(scheme, *filterLevel, byFilter, lat, lng, *suscRadiusKm, *checkLevel)
+		}
+		for i := 0; i < *clientNum; i++ {
+			var latlng s2.LatLng
+			if traces != nil {
+				latlng = s2.LatLngFromDegrees(traces[i].At(0))
+			} else {
+				latlng = randomLatLng(scheme, area, *locLevel)
+			}
+			var checker *geocheck.Checker
+			if *suscRadiusKm > 0 {
+				a, err := checkArea(latlng.Lat.Degrees(), latlng.Lng.Degrees())
+				if err != nil {
+					log.Fatalf("Area check error: %s", err)
+				}
+				checker = geocheck.NewChecker(a, *cellLevel)
+				checkers = append(checkers, checker)
+			}
+			// クライアントごとに Writer を分け、受信処理同士でロックを奪い合わないようにする
+			writers := recorders.Writers()
+			sizeWriter := sizeRecorder.NewWriter()
+			distances[i] = newDistanceTotals(bands)
+			distance := distances[i]
+			client := i
+			measurementHandler := func(t string, b []byte) {
+				msg, _, err := payload.Decode(b)
+				if err != nil {
+					log.Fatal(err)
+				}
+				now := time.Now()
+				latency := now.UnixNano()/int64(time.Millisecond) - msg.TimeMs
+				phase := msg.Phase
+				if phase < 0 || phase >= payload.Phases {
+					phase = payload.PhaseMeasure
+				}
+				if !writers[phase].Add(msg.ID, now, latency) {
+					log.Printf("[Warning] Already ended this process (ID: %v). Cannot increase counter...", msg.ID)
+					return
+				}
+				if phase == payload.PhaseMeasure {
+					sizeWriter.Add(buckets.Label(len(b)), now, latency)
+					latencies.add(client, msg.ID, latency)
+					// 軌跡に沿って受信範囲が移動する場合は、受信した時点の受信範囲の中心を Subscriber の位置とする
+					center := latlng
+					if checker != nil {
+						center = checker.Area().Center
+					}
+					distance.add(center, s2.LatLngFromDegrees(msg.Lat, msg.Lng), latency)
+				}
+				if msg.BurstMs != 0 {
+					bursts.add(client, msg.BurstMs, now, latency)
+				}
+				if checker != nil {
+					checker.Check(msg.ID, msg.Lat, msg.Lng)
+				}
+				if err := events.Write(runlog.Record{Type: runlog.TypeReceive, Client: client, ID: msg.ID, Seq: msg.Seq, TimeMs: now.UnixNano() / int64(time.Millisecond)}); err != nil {
+					log.Printf("Event log error: %s", err)
+				}
+			}
+
+			signalHandler := func(t string, b []byte) {
+				var msg PayloadSignal
+				if err := json.Unmarshal(b, &msg); err != nil {
+					log.Fatal(err)
+				}
+				if !msg.IsDone || recorder.IsClosed(msg.ID) {
+					return
+				}
+				log.Printf("Waiting %v seconds...", *waitSec)
+				time.Sleep(time.Second * time.Duration(*waitSec))
+				if recorder.Close(msg.ID) {
+					signalMu.Lock()
+					signals[msg.ID] = msg
+					signalMu.Unlock()
+					log.Printf("Done (ID: %v)", msg.ID)
+					latencies.print(msg.ID)
+				}
+			}
+
+			if traces == nil {
+				go sub(clients[i], i, latlng, *suscRadiusKm, *checkLevel, wholeWorld, events, measurementHandler, signalHandler)
+				continue
+			}
+			c, tr := clients[i], traces[i]
+			go func() {
+				sub(c, client, latlng, *suscRadiusKm, *checkLevel, wholeWorld, events, measurementHandler, signalHandler)
+				follow(c, client, tr, traceStart, *traceSpeed, time.Duration(*traceUpdate)*time.Millisecond, *suscRadiusKm, *checkLevel, checker, checkArea, events, measurementHandler)
+			}()
+		}
+		log.Print("Done launching goroutine.")
+		time.Sleep(time.Second)
+
+		doneCh := make(chan bool)
+		go func() {
+			start := time.Now()
+			for {
+				printAverages(recorders.Collect(time.Now()))
+				last := recorders.LastActivity()
+				if last.Before(start) {
+					last = start
+				}
+				if int(time.Now().Unix()-last.Unix()) > *waitSec {
+					break
+				}
+				time.Sleep(time.Millisecond * 500)
+			}
+			recorders.CloseAll()
+			endMs := time.Now().UnixNano() / int64(time.Millisecond)
+			for i := range clients {
+				if err := events.Write(runlog.Record{Type: runlog.TypeEnd, Client: i, TimeMs: endMs}); err != nil {
+					log.Printf("Event log error: %s", err)
+				}
+			}
+			printAverages(recorders.Flush())
+			printTotals(recorders)
+			latencies.printAll()
+			bursts.print()
+			printSizeTotals(sizeRecorder)
+			printDistanceTotals(distances)
+			areaCheck := geocheck.Merge(checkers)
+			printAreaCheck(areaCheck)
+			if len(*assertions) > 0 {
+				signalMu.Lock()
+				values := measuredValues(recorder, latencies, signals, areaCheck, connectErrors, *clientNum, *suscRadiusKm)
+				signalMu.Unlock()
+				if slo.Print(slo.Evaluate(*assertions, values), *allowSkip) {
+					cli.SetExitCode(1)
+				}
+			}
+			doneCh <- true
+		}()
+
+		//////////////           勝手に終了しないようにする          //////////////
+		signalCh := make(chan os.Signal, 1)
+		signal.Notify(signalCh, os.Interrupt, os.Kill)
+		for {
+			select {
+			case <-signalCh:
+				log.Print("Interrupt detected.")
+				return
+			case <-doneCh:
+				log.Print("Finished measurement.")
+				return
+			}
+		}
+	}
+}
+
+// randomLatLng は area から時刻を元に選んだ level の領域の中心を返す
+func randomLatLng(scheme topic.Scheme, area topic.Area, level int) s2.LatLng {
+	latlng := area.Locate(uint64(time.Now().UnixNano()), level)
+	log.Print(scheme.Topic(latlng))
+	return latlng
+}
+
+// wholeWorld は受信半径が 0 以下で、全てのメッセージを受信することを表す（dmb では 1 つのセルのみを受信するため false）
+func sub(c backend.Client, i int, latlng s2.LatLng, radiusKm float64, level int, wholeWorld bool, events *runlog.Writer, measurementHandler, signalHandler backend.Handler) {
+	lat, lng := latlng.Lat.Degrees(), latlng.Lng.Degrees()
+	if err := c.Subscribe(lat, lng, radiusKm, measurementHandler); err != nil {
+		log.Fatalf("MQTT Subscribe error: %s", err)
+	}
+	if err := c.SubscribeSignal(signalHandler); err != nil {
+		if _, ok := err.(backend.UnsupportedError); !ok {
+			log.Fatalf("MQTT Subscribe error: %s", err)
+		}
+	}
+	if radiusKm <= 0 && !wholeWorld {
+		return
+	}
+	writeArea(events, i, lat, lng, radiusKm, level)
+}
+
+// follow は start からの時間の speed 倍の時点の tr の位置へ、every おきに受信範囲の中心を移動する
+// 軌跡の最後の位置へ移動した後は移動しない
+func follow(c backend.Client, i int, tr *trace.Trace, start time.Time, speed float64, every time.Duration, radiusKm float64, level int, checker *geocheck.Checker, checkArea func(lat, lng float64) (*geocheck.Area, error), events *runlog.Writer, handler backend.Handler) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for range ticker.C {
+		d := time.Duration(float64(time.Since(start)) * speed)
+		lat, lng := tr.At(d)
+		if err := c.Subscribe(lat, lng, radiusKm, handler); err != nil {
+			log.Printf("MQTT Subscribe error: %s", err)
+			continue
+		}
+		if checker != nil {
+			if a, err := checkArea(lat, lng); err != nil {
+				log.Printf("Area check error: %s", err)
+			} else {
+				checker.SetArea(a)
+			}
+		}
+		writeArea(events, i, lat, lng, radiusKm, level)
+		if d >= tr.End() {
+			return
+		}
+	}
+}
+
+// newCheckArea は中心 (lat, lng)、半径 radiusKm の受信範囲の検査に使う範囲を返す
+// byFilter が true の場合は、scheme で円を filterLevel の位置まで変換したトピックフィルタにマッチする範囲とする
+func newCheckArea(scheme topic.Scheme, filterLevel int, byFilter bool, lat, lng, radiusKm float64, level int) (*geocheck.Area, error) {
+	if !byFilter {
+		return geocheck.NewArea(lat, lng, radiusKm, level), nil
+	}
+	c := s2.CapFromCenterAngle(s2.PointFromLatLng(s2.LatLngFromDegrees(lat, lng)), geocheck.KmToAngle(radiusKm))
+	filters, err := scheme.Filters(c, filterLevel)
+	if err != nil {
+		return nil, err
+	}
+	return geocheck.NewCoveredArea(lat, lng, radiusKm, topic.Covered(scheme, filters)), nil
+}
+
+// writeArea はクライアント i が受信範囲を設定したことをイベントログに記録する
+func writeArea(events *runlog.Writer, i int, lat, lng, radiusKm float64, level int) {
+	r := runlog.Record{Type: runlog.TypeArea, Client: i, TimeMs: time.Now().UnixNano() / int64(time.Millisecond), Lat: lat, Lng: lng, RadiusKm: radiusKm, Level: level}
+	if err := events.Write(r); err != nil {
+		log.Printf("Event log error: %s", err)
+	}
+}
+
+// printAverages は段階ごとの平均遅延を表示する
+// 集計から除く段階は、計測スクリプトの集計に含まれない形式で、値のある区間のみ表示する
+func printAverages(points [][]timeseries.Point) {
+	for phase, ps := range points {
+		for _, p := range ps {
+			if phase == payload.PhaseMeasure {
+				log.Printf("Average : %v [ms] [n=%v] (ID: %v)", p.Mean(), p.N, p.ID)
+			} else if p.N > 0 {
+				log.Printf("Average (%v) : %v [ms] [n=%v] (ID: %v)", payload.PhaseName(phase), p.Mean(), p.N, p.ID)
+			}
+		}
+	}
+}
+
+func printTotals(recorders *timeseries.Recorders) {
+	for phase := 0; phase < payload.Phases; phase++ {
+		recorder := recorders.Get(phase)
+		label := "Total"
+		if phase != payload.PhaseMeasure {
+			label = fmt.Sprintf("Total (%v)", payload.PhaseName(phase))
+		}
+		for _, id := range recorder.IDs() {
+			b := recorder.Total(id)
+			log.Printf("%v : %v [ms] [n=%v] [min=%v] [max=%v] (ID: %v)", label, b.Mean(), b.N, b.Min, b.Max, id)
+			if evicted := recorder.Evicted(id); evicted > 0 {
+				log.Printf("[Warning] %v messages were evicted from the buffer before being reported (ID: %v)", evicted, id)
+			}
+		}
+	}
+}
+
+// latencyTotals は PhaseMeasure の段階で受信したメッセージの遅延の分布を ID ごとに数える
+// 受信処理同士でロックを奪い合わないよう、クライアントごとに分けて数え、表示する際にまとめる
+type latencyTotals struct {
+	clients []*clientLatencies
+	sync.Mutex
+	printed map[string]bool
+}
+
+type clientLatencies struct {
+	sync.Mutex
+	byID map[string]*histogram.Histogram
+}
+
+func newLatencyTotals(n int) *latencyTotals {
+	l := &latencyTotals{printed: map[string]bool{}}
+	for i := 0; i < n; i++ {
+		l.clients = append(l.clients, &clientLatencies{byID: map[string]*histogram.Histogram{}})
+	}
+	return l
+}
+
+func (l *latencyTotals) add(client int, id string, latency int64) {
+	c := l.clients[client]
+	c.Lock()
+	h, ok := c.byID[id]
+	if !ok {
+		h = histogram.New()
+		c.byID[id] = h
+	}
+	c.Unlock()
+	h.Add(latency)
+}
+
+// merge は match を満たす ID の遅延の分布をまとめて返す
+func (l *latencyTotals) merge(match func(id string) bool) *histogram.Histogram {
+	h := histogram.New()
+	for _, c := range l.clients {
+		c.Lock()
+		for id, ch := range c.byID {
+			if match(id) {
+				h.Merge(ch)
+			}
+		}
+		c.Unlock()
+	}
+	return h
+}
+
+// print は id の遅延の分布の分位点を表示する（既に表示した ID は表示しない）
+func (l *latencyTotals) print(id string) {
+	l.Lock()
+	defer l.Unlock()
+	if l.printed[id] {
+		return
+	}
+	l.printed[id] = true
+	h := l.merge(func(v string) bool { return v == id })
+	log.Printf("Latency total : %v [ms] [n=%v] [min=%v] [max=%v] [p50=%v] [p90=%v] [p99=%v] [p99.9=%v] [negative=%v] (ID: %v)",
+		h.Mean(), h.N(), h.Min(), h.Max(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), h.Quantile(0.999), h.Negative(), id)
+	if n := h.Negative(); n > 0 {
+		log.Printf("[Warning] %v message(s) arrived before their publish time and were counted as 0 [ms]. Check the clock synchronization between hosts. (ID: %v)", n, id)
+	}
+}
+
+// printAll はまだ表示していない全ての ID の遅延の分布の分位点を表示する
+func (l *latencyTotals) printAll() {
+	ids := map[string]bool{}
+	for _, c := range l.clients {
+		c.Lock()
+		for id := range c.byID {
+			ids[id] = true
+		}
+		c.Unlock()
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	for _, id := range sorted {
+		l.print(id)
+	}
+}
+
+// burstTotals はバースト送信されたメッセージを、バーストの予定時刻ごとに集計する
+// 複数の Publisher のプロセスが同じ時刻にバースト送信した場合はまとめて集計する
+type burstTotals struct {
+	clients []*clientBursts
+}
+
+type clientBursts struct {
+	sync.Mutex
+	byTime map[int64]*burstStat
+}
+
+// burstStat は 1 つのバーストの受信
+type burstStat struct {
+	latencies   *histogram.Histogram
+	first, last time.Time
+}
+
+func (b *burstStat) merge(o *burstStat) {
+	b.latencies.Merge(o.latencies)
+	if b.first.IsZero() || o.first.Before(b.first) {
+		b.first = o.first
+	}
+	if o.last.After(b.last) {
+		b.last = o.last
+	}
+}
+
+func newBurstTotals(n int) *burstTotals {
+	b := &burstTotals{}
+	for i := 0; i < n; i++ {
+		b.clients = append(b.clients, &clientBursts{byTime: map[int64]*burstStat{}})
+	}
+	return b
+}
+
+func (b *burstTotals) add(client int, burstMs int64, at time.Time, latency int64) {
+	c := b.clients[client]
+	c.Lock()
+	defer c.Unlock()
+	s, ok := c.byTime[burstMs]
+	if !ok {
+		s = &burstStat{latencies: histogram.New(), first: at}
+		c.byTime[burstMs] = s
+	}
+	s.latencies.Add(latency)
+	s.last = at
+}
+
+// print はバーストごとに、予定時刻から最初と最後のメッセージを受信するまでの時間と、遅延のばらつきを表示する
+func (b *burstTotals) print() {
+	merged := map[int64]*burstStat{}
+	for _, c := range b.clients {
+		c.Lock()
+		for t, s := range c.byTime {
+			m, ok := merged[t]
+			if !ok {
+				m = &burstStat{latencies: histogram.New()}
+				merged[t] = m
+			}
+			m.merge(s)
+		}
+		c.Unlock()
+	}
+	times := make([]int64, 0, len(merged))
+	for t := range merged {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	for _, t := range times {
+		s, at := merged[t], time.Unix(0, t*int64(time.Millisecond))
+		h := s.latencies
+		log.Printf("Burst : %v [n=%v] [first=%v] [completion=%v] [ms] latency: [mean=%.1f] [min=%v] [p50=%v] [p90=%v] [p99=%v] [max=%v] [stddev=%.1f] [ms]",
+			at.Format("15:04:05.000"), h.N(), s.first.Sub(at).Nanoseconds()/int64(time.Millisecond), s.last.Sub(at).Nanoseconds()/int64(time.Millisecond),
+			h.Mean(), h.Min(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), h.Max(), h.Stddev())
+	}
+}
+
+// measuredValues は判定条件の評価に使う計測値を求める
+// 受信率は全てのメッセージを受信する設定（受信半径が 0 以下）で、Publisher から終了シグナルを受信した場合のみ求められる
+func measuredValues(recorder *timeseries.Recorder, latencies *latencyTotals, signals map[string]PayloadSignal, areaCheck geocheck.Report, connectErrors, clientNum int, radiusKm float64) slo.Values {
+	values := slo.Values{"connect_errors": float64(connectErrors)}
+	if h := latencies.merge(func(string) bool { return true }); h.N() > 0 {
+		values["mean"] = h.Mean()
+		values["p50"] = float64(h.Quantile(0.5))
+		values["p90"] = float64(h.Quantile(0.9))
+		values["p99"] = float64(h.Quantile(0.99))
+		values["p99.9"] = float64(h.Quantile(0.999))
+		values["max"] = float64(h.Max())
+	}
+	expected, received := int64(0), int64(0)
+	rateError := -1.
+	for id, s := range signals {
+		values["connect_errors"] += float64(s.ConnectErrors)
+		expected += s.Published * int64(clientNum)
+		received += recorder.Total(id).N
+		if s.Rate > 0 && s.MeasureMs > 0 {
+			e := math.Abs(float64(s.Published)/(float64(s.MeasureMs)/1000)-s.Rate) / s.Rate * 100
+			rateError = math.Max(rateError, e)
+		}
+	}
+	if rateError >= 0 {
+		values["rate_error"] = rateError
+	}
+	if radiusKm <= 0 && expected > 0 {
+		values["delivery"] = float64(received) / float64(expected) * 100
+	}
+	unexpected := int64(0)
+	for _, c := range areaCheck.ByID {
+		unexpected += c.OutOfArea
+	}
+	values["unexpected"] = float64(unexpected)
+	return values
+}
+
+func printSizeTotals(sizeRecorder *timeseries.Recorder) {
+	labels := sizeRecorder.IDs()
+	payload.SortLabels(labels)
+	for _, label := range labels {
+		b := sizeRecorder.Total(label)
+		log.Printf("Latency by size : %v [ms] [n=%v] [min=%v] [max=%v] (Size: %v [byte])", b.Mean(), b.N, b.Min, b.Max, label)
+	}
+}
+
+// distanceTotals は PhaseMeasure の段階で受信したメッセージの遅延の分布を、Publish した位置と Subscriber の位置の間の距離の区分と、
+// 両者を含む最小の S2 セル（共通の祖先）のレベルごとに数える
+// 受信処理同士でロックを奪い合わないよう、クライアントごとに生成し、表示する際にまとめる
+type distanceTotals struct {
+	bands []float64
+	// byBand[i] は距離が [bands[i-1], bands[i]) [km] の区分（byBand[0] は bands[0] 未満、最後は上限なし）
+	byBand []*histogram.Histogram
+	// byLevel[l+1] は共通の祖先のレベルが l のもの（byLevel[0] は立方体の異なる面で、共通の祖先が無いもの）
+	byLevel []*histogram.Histogram
+}
+
+func newDistanceTotals(bands []float64) *distanceTotals {
+	d := &distanceTotals{bands: bands}
+	for i := 0; i <= len(bands); i++ {
+		d.byBand = append(d.byBand, histogram.New())
+	}
+	for l := -1; l <= topic.MaxLevel; l++ {
+		d.byLevel = append(d.byLevel, histogram.New())
+	}
+	return d
+}
+
+// add は sub の位置の Subscriber が pub から Publish されたメッセージを latency[ms] で受信したことを数える
+func (d *distanceTotals) add(sub, pub s2.LatLng, latency int64) {
+	km := geocheck.AngleToKm(sub.Distance(pub))
+	d.byBand[sort.Search(len(d.bands), func(i int) bool { return d.bands[i] > km })].Add(latency)
+	level, ok := s2.CellIDFromLatLng(sub).CommonAncestorLevel(s2.CellIDFromLatLng(pub))
+	if !ok {
+		level = -1
+	}
+	d.byLevel[level+1].Add(latency)
+}
+
+// printDistanceTotals は全てのクライアントの遅延の分布をまとめ、距離の区分と共通の祖先のレベルごとに表示する（受信の無いものは表示しない）
+func printDistanceTotals(ds []*distanceTotals) {
+	if len(ds) == 0 {
+		return
+	}
+	merged := newDistanceTotals(ds[0].bands)
+	for _, d := range ds {
+		for i, h := range d.byBand {
+			merged.byBand[i].Merge(h)
+		}
+		for i, h := range d.byLevel {
+			merged.byLevel[i].Merge(h)
+		}
+	}
+	for i, h := range merged.byBand {
+		if h.N() == 0 {
+			continue
+		}
+		label := ""
+		switch {
+		case i == len(merged.bands):
+			label = fmt.Sprintf("%v-", merged.bands[i-1])
+		case i == 0:
+			label = fmt.Sprintf("0-%v", merged.bands[i])
+		default:
+			label = fmt.Sprintf("%v-%v", merged.bands[i-1], merged.bands[i])
+		}
+		log.Printf("Latency by distance : %v [ms] [n=%v] [min=%v] [max=%v] [p50=%v] [p90=%v] [p99=%v] (Distance: %v [km])",
+			h.Mean(), h.N(), h.Min(), h.Max(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), label)
+	}
+	for i, h := range merged.byLevel {
+		if h.N() == 0 {
+			continue
+		}
+		level := "none"
+		if i > 0 {
+			level = strconv.Itoa(i - 1)
+		}
+		log.Printf("Latency by common cell : %v [ms] [n=%v] [min=%v] [max=%v] [p50=%v] [p90=%v] [p99=%v] (Level: %v)",
+			h.Mean(), h.N(), h.Min(), h.Max(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.99), level)
+	}
+}
+
+// parseBands は昇順の距離の区切り[km]をカンマ区切りで解釈する
+func parseBands(s string) ([]float64, error) {
+	bands := []float64{}
+	for _, v := range strings.Split(s, ",") {
+		km, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || km <= 0 || (len(bands) > 0 && km <= bands[len(bands)-1]) {
+			return nil, fmt.Errorf("Invalid distance bands (inputed: %v)", s)
+		}
+		bands = append(bands, km)
+	}
+	return bands, nil
+}
+
+func printAreaCheck(report geocheck.Report) {
+	for _, id := range report.IDs() {
+		c := report.ByID[id]
+		log.Printf("Area check : in-area=%v out-of-area=%v [msg] (ID: %v)", c.InArea, c.OutOfArea, id)
+	}
+	for _, c := range report.OutOfArea {
+		log.Printf("Out-of-area delivery : %v [msg] (ID: %v, Cell: %v)", c.N, c.ID, c.Cell)
+	}
+}
+
+type PayloadSignal struct {
+	ID     string `json:"id"`
+	TimeMs int64  `json:"time_ms"`
+	IsDone bool   `json:"is_done"`
+	// Published は計測の段階（warm-up、cool-down を除く）の Publish 数
+	Published int64 `json:"published"`
+	// Rate は指定した Publish の頻度[pub/s]（指定していない場合は 0）
+	Rate float64 `json:"rate"`
+	// MeasureMs は計測の段階の時間[ms]
+	MeasureMs     int64 `json:"measure_ms"`
+	ConnectErrors int   `json:"connect_errors"`
+}
+
+func printResource(r resource.Sample, cpuLimit, gcLimit float64) {
+	log.Printf("Resource : %v", r)
+	if reason := r.Bottleneck(cpuLimit, gcLimit); reason != "" {
+		log.Printf("[Warning] Load generator bottleneck : %v", reason)
+	}
+}
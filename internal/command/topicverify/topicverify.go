@@ -0,0 +1,66 @@
+package topicverify
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/topic"
+)
+
+// Command は各トピック名の方式について、位置とトピック名の相互変換とトピックフィルタを検査するサブコマンド
+var Command = cli.Command{
+	Name:    "topic-verify",
+	Summary: "トピック名の方式の相互変換とトピックフィルタを検査する",
+	Define:  define,
+}
+
+func define(fs *flag.FlagSet) func() {
+	schemes := fs.String("schemes", strings.Join(topic.Names(), ","), "検査するトピック名の方式（カンマ区切り）")
+	depths := fs.String("depths", "0,1,3", "検査する根を除いたトピックの階層数（カンマ区切り、0 の場合はトピックのレベルと同じ）")
+	separators := fs.String("separators", ",.", "検査する区切り文字（カンマ区切り、空文字も可）")
+	n := fs.Int("n", 100, "方式とレイアウトの組ごとに検査する地点の数")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "検査する地点を生成するためのシード値")
+	return func() {
+		log.Print("Starting...")
+
+		// オプションの表示
+		log.Printf("OPTION Schemes                    : %v", *schemes)
+		log.Printf("OPTION Depths                     : %v", *depths)
+		log.Printf("OPTION Separators                 : %v", *separators)
+		log.Printf("OPTION Points                     : %v", *n)
+		log.Printf("OPTION Seed                       : %v", *seed)
+
+		rng := rand.New(rand.NewSource(*seed))
+		failed := 0
+		for _, name := range strings.Split(*schemes, ",") {
+			for _, d := range strings.Split(*depths, ",") {
+				var depth int
+				if _, err := fmt.Sscan(d, &depth); err != nil {
+					log.Fatalf("Topic depth error: %s", err)
+				}
+				for _, sep := range strings.Split(*separators, ",") {
+					s, err := topic.NewScheme(name, -1, depth, sep)
+					if err != nil {
+						log.Fatalf("Topic scheme error: %s", err)
+					}
+					result, err := topic.Verify(s, rng, *n)
+					if err != nil {
+						failed++
+						log.Printf("[Error] %s (Scheme: %v, Layout: %v)", err, s.Name(), s.Layout())
+						continue
+					}
+					log.Printf("Verify : round-trip=%v filters=%v skipped=%v (Scheme: %v, Layout: %v)", result.RoundTrip, result.Filters, result.Skipped, s.Name(), s.Layout())
+				}
+			}
+		}
+		if failed > 0 {
+			log.Fatalf("%v layouts failed verification.", failed)
+		}
+		log.Print("All layouts passed verification.")
+	}
+}
@@ -0,0 +1,49 @@
+package verify
+
+import (
+	"flag"
+	"log"
+
+	"location-based-mqtt-evaluation-tool/internal/cli"
+	"location-based-mqtt-evaluation-tool/internal/command/seal"
+	"location-based-mqtt-evaluation-tool/internal/provenance"
+)
+
+// Command は台帳に記録された結果ファイルが改ざん・削除されていないかを検査するサブコマンド
+// 問題が見つかった場合は終了コード 1 で終了する
+var Command = cli.Command{
+	Name:    "verify",
+	Summary: "台帳に記録された結果ファイルが改ざん・削除されていないかを検査する",
+	Define:  define,
+}
+
+func define(fs *flag.FlagSet) func() {
+	ledger := fs.String("ledger", seal.DefaultLedger(), "台帳のパス")
+	head := fs.String("head", "", "控えておいた台帳の末尾のハッシュ（指定した場合は、それ以降の行が削除されていないかも検査する）")
+	return func() {
+		problems, last, err := provenance.Verify(*ledger)
+		if err != nil {
+			log.Fatalf("Ledger error: %s", err)
+		}
+		if *head != "" {
+			entries, _ := provenance.ReadLedger(*ledger)
+			found := false
+			for _, e := range entries {
+				found = found || e.Hash == *head
+			}
+			if !found {
+				problems = append(problems, provenance.Problem{Seq: last.Seq, Msg: "Recorded head is not in the ledger (entries may have been removed)"})
+			}
+		}
+		for _, p := range problems {
+			log.Printf("Verify error : %v", p)
+		}
+		log.Printf("Ledger head : seq=%v hash=%v (%v)", last.Seq, last.Hash, *ledger)
+		if len(problems) > 0 {
+			log.Printf("Verification failed: %v problem(s)", len(problems))
+			cli.SetExitCode(1)
+			return
+		}
+		log.Print("Verification succeeded.")
+	}
+}
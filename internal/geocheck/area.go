@@ -0,0 +1,81 @@
+// Package geocheck は位置情報付きメッセージが受信範囲内から Publish されたものかを検査する
+package geocheck
+
+import (
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+// EarthRadiusKm は地球の平均半径[km]
+const EarthRadiusKm = 6371.01
+
+// maxLevel は S2 セルの最大レベル
+const maxLevel = 30
+
+// Area は Subscriber の受信範囲
+type Area struct {
+	Center   s2.LatLng
+	RadiusKm float64
+	// Level が 0 以上の場合は、円をその Level のセルで被覆した範囲を受信範囲とする
+	Level    int
+	cap      s2.Cap
+	covering s2.CellUnion
+	// covered が nil でない場合は、covered を満たす位置を受信範囲とする
+	covered func(lat, lng float64) bool
+}
+
+// NewArea は中心 (lat, lng)、半径 radiusKm の受信範囲を生成する
+// level が負の場合は円そのもの、0 以上の場合は円を level のセルで被覆した範囲を受信範囲とする
+func NewArea(lat, lng, radiusKm float64, level int) *Area {
+	center := s2.LatLngFromDegrees(lat, lng)
+	a := &Area{
+		Center:   center,
+		RadiusKm: radiusKm,
+		Level:    level,
+		cap:      s2.CapFromCenterAngle(s2.PointFromLatLng(center), KmToAngle(radiusKm)),
+	}
+	if level >= 0 {
+		if level > maxLevel {
+			level = maxLevel
+			a.Level = level
+		}
+		rc := &s2.RegionCoverer{MinLevel: level, MaxLevel: level, MaxCells: 1 << 16}
+		a.covering = rc.Covering(a.cap)
+	}
+	return a
+}
+
+// NewCoveredArea は中心 (lat, lng)、半径 radiusKm の円を実際に受信する範囲で被覆したものを受信範囲として生成する
+// covered は (lat, lng) が実際に受信する範囲（例: Subscribe したトピックフィルタにマッチする範囲）内かを返す
+func NewCoveredArea(lat, lng, radiusKm float64, covered func(lat, lng float64) bool) *Area {
+	a := NewArea(lat, lng, radiusKm, -1)
+	a.covered = covered
+	return a
+}
+
+// Contains は (lat, lng) が受信範囲内かを返す
+func (a *Area) Contains(lat, lng float64) bool {
+	if a.covered != nil {
+		return a.covered(lat, lng)
+	}
+	p := s2.PointFromLatLng(s2.LatLngFromDegrees(lat, lng))
+	if a.Level >= 0 {
+		return a.covering.ContainsPoint(p)
+	}
+	return a.cap.ContainsPoint(p)
+}
+
+// Covering は受信範囲を被覆するセルを返す（Level が負の場合は nil）
+func (a *Area) Covering() s2.CellUnion {
+	return a.covering
+}
+
+// KmToAngle は地表上の距離[km]を中心角に変換する
+func KmToAngle(km float64) s1.Angle {
+	return s1.Angle(km / EarthRadiusKm)
+}
+
+// AngleToKm は中心角を地表上の距離[km]に変換する
+func AngleToKm(a s1.Angle) float64 {
+	return a.Radians() * EarthRadiusKm
+}
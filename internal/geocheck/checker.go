@@ -0,0 +1,122 @@
+package geocheck
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang/geo/s2"
+)
+
+// Count は ID ごとの検査結果
+type Count struct {
+	InArea    int64
+	OutOfArea int64
+}
+
+// CellCount は受信範囲外から Publish されたメッセージの、Publish 元セルごとの件数
+type CellCount struct {
+	ID   string
+	Cell string
+	N    int64
+}
+
+type cellKey struct {
+	id   string
+	cell s2.CellID
+}
+
+// Checker は 1 つの受信範囲についての検査結果を保持する
+// 受信範囲はクライアントごとに異なるため、クライアントごとに生成する
+type Checker struct {
+	sync.Mutex
+	area      *Area
+	cellLevel int
+	counts    map[string]*Count
+	outOfArea map[cellKey]int64
+}
+
+// NewChecker は area を受信範囲とする Checker を生成する
+// 受信範囲外の件数は Publish 元の位置を cellLevel のセルに丸めて集計する
+func NewChecker(area *Area, cellLevel int) *Checker {
+	return &Checker{area: area, cellLevel: cellLevel, counts: map[string]*Count{}, outOfArea: map[cellKey]int64{}}
+}
+
+// Area は受信範囲を返す
+func (c *Checker) Area() *Area {
+	c.Lock()
+	defer c.Unlock()
+	return c.area
+}
+
+// SetArea は受信範囲を area に変更する（受信範囲が移動する場合に使う）
+// 変更前に Publish され、変更後に受信したメッセージは変更後の受信範囲で検査する
+func (c *Checker) SetArea(area *Area) {
+	c.Lock()
+	defer c.Unlock()
+	c.area = area
+}
+
+// Check は ID が id の Publisher が (lat, lng) で Publish したメッセージを検査し、受信範囲内かを返す
+func (c *Checker) Check(id string, lat, lng float64) bool {
+	c.Lock()
+	defer c.Unlock()
+	ok := c.area.Contains(lat, lng)
+	count, exists := c.counts[id]
+	if !exists {
+		count = &Count{}
+		c.counts[id] = count
+	}
+	if ok {
+		count.InArea++
+		return true
+	}
+	count.OutOfArea++
+	cell := s2.CellIDFromLatLng(s2.LatLngFromDegrees(lat, lng)).Parent(c.cellLevel)
+	c.outOfArea[cellKey{id: id, cell: cell}]++
+	return false
+}
+
+// Report は複数の Checker の検査結果を合算したもの
+type Report struct {
+	ByID      map[string]Count
+	OutOfArea []CellCount
+}
+
+// IDs は検査結果のある ID を昇順で返す
+func (r Report) IDs() []string {
+	ids := make([]string, 0, len(r.ByID))
+	for id := range r.ByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Merge は checkers の検査結果を合算する
+func Merge(checkers []*Checker) Report {
+	r := Report{ByID: map[string]Count{}}
+	cells := map[cellKey]int64{}
+	for _, c := range checkers {
+		c.Lock()
+		for id, count := range c.counts {
+			sum := r.ByID[id]
+			sum.InArea += count.InArea
+			sum.OutOfArea += count.OutOfArea
+			r.ByID[id] = sum
+		}
+		for k, n := range c.outOfArea {
+			cells[k] += n
+		}
+		c.Unlock()
+	}
+	for k, n := range cells {
+		r.OutOfArea = append(r.OutOfArea, CellCount{ID: k.id, Cell: k.cell.ToToken(), N: n})
+	}
+	sort.Slice(r.OutOfArea, func(i, j int) bool {
+		if r.OutOfArea[i].ID != r.OutOfArea[j].ID {
+			return r.OutOfArea[i].ID < r.OutOfArea[j].ID
+		}
+		return r.OutOfArea[i].Cell < r.OutOfArea[j].Cell
+	})
+	return r
+}
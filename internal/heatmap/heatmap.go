@@ -0,0 +1,98 @@
+// Package heatmap は Publish 元セルごとの集計結果を、セルの境界を多角形とする GeoJSON（FeatureCollection）として書き出す
+// GIS ツールで読み込み、Publish 数や遅延の地理的な偏りを確認するために使う
+package heatmap
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/oracle"
+)
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string     `json:"type"`
+	Geometry   geometry   `json:"geometry"`
+	Properties properties `json:"properties"`
+}
+
+type geometry struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// properties はセルごとの値
+// 値を求められない項目（遅延の無いセルの分位点など）は null とする
+type properties struct {
+	Cell     string `json:"cell"`
+	Level    int    `json:"level"`
+	Sent     int64  `json:"sent"`
+	Received int64  `json:"received"`
+	Expected int64  `json:"expected"`
+	Missed   int64  `json:"missed"`
+	// Delivery は受信すべきだったメッセージのうち受信した割合[%]
+	Delivery *float64 `json:"delivery"`
+	// 遅延[ms]
+	LatencyN int64    `json:"latency_n"`
+	Mean     *float64 `json:"latency_mean"`
+	P50      *int64   `json:"latency_p50"`
+	P90      *int64   `json:"latency_p90"`
+	P99      *int64   `json:"latency_p99"`
+	Max      *int64   `json:"latency_max"`
+}
+
+// Write は cells を path に GeoJSON として書き出す
+func Write(path string, cells []oracle.CellResult) error {
+	fc := featureCollection{Type: "FeatureCollection", Features: make([]feature, 0, len(cells))}
+	for _, c := range cells {
+		fc.Features = append(fc.Features, feature{Type: "Feature", Geometry: polygon(c.Cell), Properties: propertiesOf(c)})
+	}
+	b, err := json.Marshal(fc)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// polygon はセルの 4 つの頂点を反時計回りに結んだ多角形を返す（GeoJSON の座標は [経度, 緯度] の順）
+func polygon(id s2.CellID) geometry {
+	cell := s2.CellFromCellID(id)
+	ring := make([][2]float64, 0, 5)
+	for i := 0; i < 4; i++ {
+		ll := s2.LatLngFromPoint(cell.Vertex(i))
+		ring = append(ring, [2]float64{ll.Lng.Degrees(), ll.Lat.Degrees()})
+	}
+	ring = append(ring, ring[0])
+	return geometry{Type: "Polygon", Coordinates: [][][2]float64{ring}}
+}
+
+func propertiesOf(c oracle.CellResult) properties {
+	p := properties{Cell: c.Cell.ToToken(), Level: c.Cell.Level(), Sent: c.Sent, Received: c.Received, Expected: c.Expected, Missed: c.Missed}
+	if c.Expected > 0 {
+		p.Delivery = float64Ptr(float64(c.Expected-c.Missed) / float64(c.Expected) * 100)
+	}
+	h := c.Latency
+	if h == nil || h.N() == 0 {
+		return p
+	}
+	p.LatencyN = h.N()
+	if m := h.Mean(); !math.IsNaN(m) {
+		p.Mean = float64Ptr(m)
+	}
+	p.P50, p.P90, p.P99, p.Max = int64Ptr(h.Quantile(0.5)), int64Ptr(h.Quantile(0.9)), int64Ptr(h.Quantile(0.99)), int64Ptr(h.Max())
+	return p
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
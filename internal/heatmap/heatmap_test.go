@@ -0,0 +1,148 @@
+package heatmap
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/histogram"
+	"location-based-mqtt-evaluation-tool/internal/oracle"
+)
+
+func cellAt(lat, lng float64, level int) s2.CellID {
+	return s2.CellIDFromLatLng(s2.LatLngFromDegrees(lat, lng)).Parent(level)
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heatmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	latency := histogram.New()
+	for _, v := range []int64{10, 20, 30} {
+		latency.Add(v)
+	}
+	tests := []struct {
+		name     string
+		lat, lng float64
+		cell     oracle.CellResult
+		// want は null でない項目（それ以外の遅延と delivery は null）
+		want map[string]interface{}
+	}{
+		{"latency", 35.68, 139.76, oracle.CellResult{Sent: 10, Received: 6, Expected: 4, Missed: 1, Latency: latency},
+			map[string]interface{}{"sent": 10.0, "received": 6.0, "expected": 4.0, "missed": 1.0, "delivery": 75.0,
+				"latency_n": 3.0, "latency_mean": 20.0, "latency_p50": 20.0, "latency_p90": 30.0, "latency_p99": 30.0, "latency_max": 30.0}},
+		// 受信すべきメッセージも遅延も無いセル
+		{"empty", -33.87, 151.21, oracle.CellResult{Sent: 2, Latency: histogram.New()},
+			map[string]interface{}{"sent": 2.0, "received": 0.0, "expected": 0.0, "missed": 0.0, "latency_n": 0.0}},
+		{"nil latency", 0, 0, oracle.CellResult{Expected: 2, Missed: 2},
+			map[string]interface{}{"sent": 0.0, "received": 0.0, "expected": 2.0, "missed": 2.0, "delivery": 0.0, "latency_n": 0.0}},
+	}
+	cells := make([]oracle.CellResult, len(tests))
+	for i, tt := range tests {
+		cells[i] = tt.cell
+		cells[i].Cell = cellAt(tt.lat, tt.lng, 10)
+	}
+	path := filepath.Join(dir, "heatmap.geojson")
+	if err := Write(path, cells); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fc struct {
+		Type     string
+		Features []struct {
+			Type     string
+			Geometry struct {
+				Type        string
+				Coordinates [][][2]float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(b, &fc); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != len(tests) {
+		t.Fatalf("type = %v, %v features, want FeatureCollection, %v features", fc.Type, len(fc.Features), len(tests))
+	}
+
+	for i, tt := range tests {
+		f := fc.Features[i]
+		if f.Type != "Feature" || f.Geometry.Type != "Polygon" || len(f.Geometry.Coordinates) != 1 {
+			t.Errorf("%v: type = %v, geometry = %v with %v rings", tt.name, f.Type, f.Geometry.Type, len(f.Geometry.Coordinates))
+			continue
+		}
+		// 閉じた 4 角形で、反時計回り（符号付き面積が正）で、中心がセルの中心と一致する
+		ring := f.Geometry.Coordinates[0]
+		if len(ring) != 5 || ring[0] != ring[4] {
+			t.Errorf("%v: ring = %v, want 5 points with the first and last equal", tt.name, ring)
+			continue
+		}
+		area, lng, lat := 0.0, 0.0, 0.0
+		for j := 0; j < 4; j++ {
+			a, b := ring[j], ring[j+1]
+			area += a[0]*b[1] - b[0]*a[1]
+			lng += a[0] / 4
+			lat += a[1] / 4
+		}
+		if area <= 0 {
+			t.Errorf("%v: ring %v is not counterclockwise", tt.name, ring)
+		}
+		if got := cellAt(lat, lng, 10); got != cells[i].Cell {
+			t.Errorf("%v: ring center (%v, %v) is in %v, want %v", tt.name, lat, lng, got.ToToken(), cells[i].Cell.ToToken())
+		}
+
+		p := f.Properties
+		if p["cell"] != cells[i].Cell.ToToken() || p["level"] != 10.0 {
+			t.Errorf("%v: cell = %v level = %v, want %v 10", tt.name, p["cell"], p["level"], cells[i].Cell.ToToken())
+		}
+		for _, key := range []string{"sent", "received", "expected", "missed", "delivery", "latency_n", "latency_mean", "latency_p50", "latency_p90", "latency_p99", "latency_max"} {
+			v, ok := p[key]
+			if !ok {
+				t.Errorf("%v: %v is missing", tt.name, key)
+				continue
+			}
+			want, ok := tt.want[key]
+			switch {
+			case !ok && v != nil:
+				t.Errorf("%v: %v = %v, want null", tt.name, key, v)
+			case ok && v == nil:
+				t.Errorf("%v: %v = null, want %v", tt.name, key, want)
+			case ok && v != want:
+				t.Errorf("%v: %v = %v, want %v", tt.name, key, v, want)
+			}
+		}
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heatmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "heatmap.geojson")
+	if err := Write(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// セルが無くても features は空の配列とする
+	if got := string(b); got != `{"type":"FeatureCollection","features":[]}` {
+		t.Errorf("Write(nil) = %v", got)
+	}
+	if err := Write(filepath.Join(dir, "missing", "heatmap.geojson"), nil); err == nil {
+		t.Errorf("Write to a missing directory succeeded, want error")
+	}
+}
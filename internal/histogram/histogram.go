@@ -0,0 +1,217 @@
+// Package histogram は値の分布を、2 のべき乗ごとに一定数に分割した区間で数える
+// 区間の幅は値の大きさに比例するため、どの大きさの値も同じ相対誤差（1/subBuckets 以下）で分位点を求められる
+package histogram
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// subBuckets は 2 のべき乗ごとの区間の分割数（2 のべき乗）
+const subBuckets = 16
+
+// subBits は log2(subBuckets)
+const subBits = 4
+
+// Histogram は 0 以上の整数値の分布
+// 複数の goroutine から同時に呼び出してよい
+type Histogram struct {
+	sync.Mutex
+	counts []int64
+	n      int64
+	sum    int64
+	sumSq  float64
+	min    int64
+	max    int64
+	// negative は 0 として数えた負の値の数（時計のずれなどで遅延が負になった場合）
+	negative int64
+}
+
+// Bucket は区間 [Lo, Hi) の値の数
+type Bucket struct {
+	Lo int64
+	Hi int64
+	N  int64
+}
+
+// New は空の Histogram を生成する
+func New() *Histogram {
+	return &Histogram{}
+}
+
+func index(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	s := bits.Len64(uint64(v)) - subBits - 1
+	return (s+1)*subBuckets + int(v>>uint(s)) - subBuckets
+}
+
+func bounds(i int) (int64, int64) {
+	if i < subBuckets {
+		return int64(i), int64(i + 1)
+	}
+	s := uint(i/subBuckets - 1)
+	m := int64(i%subBuckets + subBuckets)
+	return m << s, (m + 1) << s
+}
+
+// Add は v を数える
+// 負の値は 0 として数え、その数を Negative で返す
+func (h *Histogram) Add(v int64) {
+	h.Lock()
+	defer h.Unlock()
+	if v < 0 {
+		h.negative++
+		v = 0
+	}
+	i := index(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	if h.n == 0 || v < h.min {
+		h.min = v
+	}
+	if h.n == 0 || v > h.max {
+		h.max = v
+	}
+	h.n++
+	h.sum += v
+	h.sumSq += float64(v) * float64(v)
+}
+
+// Merge は o の値を h に加える
+func (h *Histogram) Merge(o *Histogram) {
+	o.Lock()
+	counts := append([]int64{}, o.counts...)
+	n, sum, sumSq, min, max, negative := o.n, o.sum, o.sumSq, o.min, o.max, o.negative
+	o.Unlock()
+	if n == 0 {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	if len(counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]int64, len(counts)-len(h.counts))...)
+	}
+	for i, c := range counts {
+		h.counts[i] += c
+	}
+	if h.n == 0 || min < h.min {
+		h.min = min
+	}
+	if h.n == 0 || max > h.max {
+		h.max = max
+	}
+	h.n += n
+	h.sum += sum
+	h.sumSq += sumSq
+	h.negative += negative
+}
+
+// N は数えた値の数を返す
+func (h *Histogram) N() int64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.n
+}
+
+// Negative は 0 として数えた負の値の数を返す
+func (h *Histogram) Negative() int64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.negative
+}
+
+// Mean は平均を返す（値が無い場合は 0）
+func (h *Histogram) Mean() float64 {
+	h.Lock()
+	defer h.Unlock()
+	if h.n == 0 {
+		return 0.
+	}
+	return float64(h.sum) / float64(h.n)
+}
+
+// Stddev は標準偏差を返す（値が無い場合は 0）
+func (h *Histogram) Stddev() float64 {
+	h.Lock()
+	defer h.Unlock()
+	if h.n == 0 {
+		return 0.
+	}
+	m := float64(h.sum) / float64(h.n)
+	return math.Sqrt(math.Max(0, h.sumSq/float64(h.n)-m*m))
+}
+
+// Min は最小値を返す（値が無い場合は 0）
+func (h *Histogram) Min() int64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.min
+}
+
+// Max は最大値を返す（値が無い場合は 0）
+func (h *Histogram) Max() int64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.max
+}
+
+// Quantile は q (0 <= q <= 1) 分位点を含む区間の中央の値を返す（値が無い場合は 0）
+func (h *Histogram) Quantile(q float64) int64 {
+	h.Lock()
+	defer h.Unlock()
+	if h.n == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.n)))
+	if rank < 1 {
+		rank = 1
+	}
+	cum := int64(0)
+	for i, c := range h.counts {
+		cum += c
+		if cum >= rank {
+			lo, hi := bounds(i)
+			v := lo + (hi-lo-1)/2
+			if v < h.min {
+				v = h.min
+			}
+			if v > h.max {
+				v = h.max
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// Octaves は値の数を 2 のべき乗ごとの区間 [0, 1), [1, 2), [2, 4), ... にまとめて返す（値の無い区間は除く）
+func (h *Histogram) Octaves() []Bucket {
+	h.Lock()
+	defer h.Unlock()
+	octaves := []Bucket{}
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		lo, _ := bounds(i)
+		o := Bucket{}
+		if lo > 0 {
+			e := uint(bits.Len64(uint64(lo)) - 1)
+			o.Lo, o.Hi = 1<<e, 1<<(e+1)
+		} else {
+			o.Lo, o.Hi = 0, 1
+		}
+		if n := len(octaves); n > 0 && octaves[n-1].Lo == o.Lo {
+			octaves[n-1].N += c
+			continue
+		}
+		o.N = c
+		octaves = append(octaves, o)
+	}
+	return octaves
+}
@@ -0,0 +1,46 @@
+package histogram
+
+import "testing"
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []int64
+		n        int64
+		min, max int64
+		negative int64
+		p50      int64
+	}{
+		{"empty", nil, 0, 0, 0, 0, 0},
+		{"small", []int64{1, 2, 3}, 3, 1, 3, 0, 2},
+		// 負の値は 0 として数え、別に数える
+		{"negative", []int64{-5, -1, 4}, 3, 0, 4, 2, 0},
+		{"large", []int64{1000, 1000, 1000000}, 3, 1000, 1000000, 0, 1000},
+	}
+	for _, tt := range tests {
+		h := New()
+		for _, v := range tt.values {
+			h.Add(v)
+		}
+		if h.N() != tt.n || h.Min() != tt.min || h.Max() != tt.max || h.Negative() != tt.negative {
+			t.Errorf("%v: n=%v min=%v max=%v negative=%v, want %v %v %v %v", tt.name, h.N(), h.Min(), h.Max(), h.Negative(), tt.n, tt.min, tt.max, tt.negative)
+		}
+		// 分位点は区間の幅（値の 1/16）以内の誤差で求める
+		if p := h.Quantile(0.5); p < tt.p50-tt.p50/16 || p > tt.p50+tt.p50/16 {
+			t.Errorf("%v: p50 = %v, want about %v", tt.name, p, tt.p50)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(), New()
+	a.Add(-1)
+	a.Add(10)
+	b.Add(-2)
+	b.Add(20)
+	a.Merge(b)
+	a.Merge(New())
+	if a.N() != 4 || a.Negative() != 2 || a.Min() != 0 || a.Max() != 20 {
+		t.Errorf("Merge: n=%v negative=%v min=%v max=%v, want 4 2 0 20", a.N(), a.Negative(), a.Min(), a.Max())
+	}
+}
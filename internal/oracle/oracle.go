@@ -0,0 +1,268 @@
+// Package oracle は Publisher と Subscriber のイベントログから、各 Subscriber が受信すべきだったメッセージを求め、
+// 実際に受信したメッセージと突き合わせる
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/geocheck"
+	"location-based-mqtt-evaluation-tool/internal/histogram"
+	"location-based-mqtt-evaluation-tool/internal/payload"
+	"location-based-mqtt-evaluation-tool/internal/runlog"
+)
+
+// Options は突き合わせの設定
+type Options struct {
+	// GraceMs は受信範囲の設定・変更・終了の前後で、受信の有無を判定しない期間[ms]
+	GraceMs int64
+	// CellLevel は Publish 元の位置ごとに受信漏れなどを集計するセルのレベル
+	CellLevel int
+}
+
+type key struct {
+	id  string
+	seq int64
+}
+
+type publication struct {
+	runlog.Record
+	cell s2.CellID
+}
+
+// span は 1 つの受信範囲が有効だった期間 [from, to)
+// area が nil の場合は全てのメッセージを受信する
+type span struct {
+	from, to int64
+	area     *geocheck.Area
+}
+
+type client struct {
+	name     string
+	spans    []span
+	received map[key]int
+}
+
+// ClientResult はクライアントごとの突き合わせ結果
+type ClientResult struct {
+	Client string
+	// Expected は受信すべきだったメッセージの数
+	Expected int64
+	// Received は受信したメッセージの数（重複を除く）
+	Received int64
+	// Matched は受信すべきで、実際に受信したメッセージの数
+	Matched int64
+	// Missed は受信すべきだったが、受信しなかったメッセージの数
+	Missed int64
+	// Unexpected は受信すべきでないのに受信したメッセージの数
+	Unexpected int64
+	// Ambiguous は受信範囲の変更前後など、判定しなかったメッセージの受信数
+	Ambiguous int64
+	// Unknown は Publisher のログに存在しないメッセージの受信数
+	Unknown int64
+	// Duplicate は 2 回目以降に受信したメッセージの数
+	Duplicate int64
+}
+
+// Recall は受信すべきメッセージのうち実際に受信した割合を返す
+func (c ClientResult) Recall() float64 {
+	if c.Expected == 0 {
+		return math.NaN()
+	}
+	return float64(c.Matched) / float64(c.Expected)
+}
+
+// Precision は判定対象の受信メッセージのうち受信すべきだったものの割合を返す
+func (c ClientResult) Precision() float64 {
+	judged := c.Matched + c.Unexpected
+	if judged == 0 {
+		return math.NaN()
+	}
+	return float64(c.Matched) / float64(judged)
+}
+
+func (c *ClientResult) add(o ClientResult) {
+	c.Expected += o.Expected
+	c.Received += o.Received
+	c.Matched += o.Matched
+	c.Missed += o.Missed
+	c.Unexpected += o.Unexpected
+	c.Ambiguous += o.Ambiguous
+	c.Unknown += o.Unknown
+	c.Duplicate += o.Duplicate
+}
+
+// CellResult は Publish 元セルごとの集計結果
+type CellResult struct {
+	Cell s2.CellID
+	// Sent はセル内から Publish されたメッセージの数
+	Sent int64
+	// Received はセル内から Publish されたメッセージを受信した数（全クライアントの合計、重複を含む）
+	Received int64
+	Expected int64
+	Missed   int64
+	// Latency は計測の段階にセル内から Publish されたメッセージの、Publish から各受信までの時間[ms]の分布
+	Latency *histogram.Histogram
+}
+
+// Result は突き合わせ結果
+type Result struct {
+	Total   ClientResult
+	Clients []ClientResult
+	Cells   []CellResult
+	// Latency は計測の段階に Publish されたメッセージの、Publish から各受信までの時間[ms]の分布
+	Latency *histogram.Histogram
+}
+
+// Evaluate は pubLogs（Publisher のイベントログ）と subLogs（Subscriber のイベントログ）を突き合わせる
+func Evaluate(pubLogs, subLogs []string, opts Options) (Result, error) {
+	pubs := map[key]*publication{}
+	cells := map[s2.CellID]*CellResult{}
+	cellOf := func(id s2.CellID) *CellResult {
+		cell, ok := cells[id]
+		if !ok {
+			cell = &CellResult{Cell: id, Latency: histogram.New()}
+			cells[id] = cell
+		}
+		return cell
+	}
+	for _, path := range pubLogs {
+		err := runlog.Read(path, func(r runlog.Record) error {
+			if r.Type != runlog.TypePublish {
+				return nil
+			}
+			cell := s2.CellIDFromLatLng(s2.LatLngFromDegrees(r.Lat, r.Lng)).Parent(opts.CellLevel)
+			pubs[key{id: r.ID, seq: r.Seq}] = &publication{Record: r, cell: cell}
+			cellOf(cell).Sent++
+			return nil
+		})
+		if err != nil {
+			return Result{}, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+
+	latency := histogram.New()
+	clients := []*client{}
+	for _, path := range subLogs {
+		byIndex := map[int]*client{}
+		err := runlog.Read(path, func(r runlog.Record) error {
+			c, ok := byIndex[r.Client]
+			if !ok {
+				c = &client{name: fmt.Sprintf("%v#%v", path, r.Client), received: map[key]int{}}
+				byIndex[r.Client] = c
+				clients = append(clients, c)
+			}
+			switch r.Type {
+			case runlog.TypeArea:
+				c.close(r.TimeMs)
+				s := span{from: r.TimeMs, to: math.MaxInt64}
+				if r.RadiusKm > 0 {
+					s.area = geocheck.NewArea(r.Lat, r.Lng, r.RadiusKm, r.Level)
+				}
+				c.spans = append(c.spans, s)
+			case runlog.TypeEnd:
+				c.close(r.TimeMs)
+			case runlog.TypeReceive:
+				k := key{id: r.ID, seq: r.Seq}
+				c.received[k]++
+				if p, ok := pubs[k]; ok {
+					cell := cellOf(p.cell)
+					cell.Received++
+					if p.Phase == payload.PhaseMeasure {
+						latency.Add(r.TimeMs - p.TimeMs)
+						cell.Latency.Add(r.TimeMs - p.TimeMs)
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return Result{}, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+
+	// セルの集計結果が実行ごとに変わらないよう、Publish 順に走査する
+	ordered := make([]*publication, 0, len(pubs))
+	for _, p := range pubs {
+		ordered = append(ordered, p)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].TimeMs != ordered[j].TimeMs {
+			return ordered[i].TimeMs < ordered[j].TimeMs
+		}
+		if ordered[i].ID != ordered[j].ID {
+			return ordered[i].ID < ordered[j].ID
+		}
+		return ordered[i].Seq < ordered[j].Seq
+	})
+
+	result := Result{Latency: latency}
+	for _, c := range clients {
+		cr := ClientResult{Client: c.name}
+		expected := map[key]bool{}
+		ambiguous := map[key]bool{}
+		for _, p := range ordered {
+			s, ok := c.spanAt(p.TimeMs)
+			if !ok || s.area != nil && !s.area.Contains(p.Lat, p.Lng) {
+				continue
+			}
+			k := key{id: p.ID, seq: p.Seq}
+			if p.TimeMs < s.from+opts.GraceMs || p.TimeMs >= s.to-opts.GraceMs {
+				ambiguous[k] = true
+				continue
+			}
+			expected[k] = true
+			cell := cellOf(p.cell)
+			cell.Expected++
+			if c.received[k] == 0 {
+				cell.Missed++
+			}
+		}
+		cr.Expected = int64(len(expected))
+		for k, n := range c.received {
+			cr.Received++
+			cr.Duplicate += int64(n - 1)
+			switch {
+			case expected[k]:
+				cr.Matched++
+			case ambiguous[k]:
+				cr.Ambiguous++
+			case pubs[k] == nil:
+				cr.Unknown++
+			default:
+				cr.Unexpected++
+			}
+		}
+		cr.Missed = cr.Expected - cr.Matched
+		result.Total.add(cr)
+		result.Clients = append(result.Clients, cr)
+	}
+	result.Total.Client = "total"
+	for _, cell := range cells {
+		result.Cells = append(result.Cells, *cell)
+	}
+	sort.Slice(result.Cells, func(i, j int) bool { return result.Cells[i].Cell < result.Cells[j].Cell })
+	return result, nil
+}
+
+// close は現在の受信範囲を t で終了する
+func (c *client) close(t int64) {
+	if n := len(c.spans); n > 0 && c.spans[n-1].to > t {
+		c.spans[n-1].to = t
+	}
+}
+
+// spanAt は時刻 t に有効だった受信範囲を返す
+func (c *client) spanAt(t int64) (span, bool) {
+	i := sort.Search(len(c.spans), func(i int) bool { return c.spans[i].from > t })
+	if i == 0 {
+		return span{}, false
+	}
+	s := c.spans[i-1]
+	if t >= s.to {
+		return span{}, false
+	}
+	return s, true
+}
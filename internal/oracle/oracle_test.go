@@ -0,0 +1,187 @@
+package oracle
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/payload"
+	"location-based-mqtt-evaluation-tool/internal/runlog"
+)
+
+func writeLog(t *testing.T, path string, records []runlog.Record) {
+	w, err := runlog.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pub(id string, seq, timeMs int64, lat, lng float64, phase int) runlog.Record {
+	return runlog.Record{Type: runlog.TypePublish, ID: id, Seq: seq, TimeMs: timeMs, Lat: lat, Lng: lng, Phase: phase}
+}
+
+func recv(client int, id string, seq, timeMs int64) runlog.Record {
+	return runlog.Record{Type: runlog.TypeReceive, Client: client, ID: id, Seq: seq, TimeMs: timeMs}
+}
+
+func area(client int, timeMs int64, lat, lng float64) runlog.Record {
+	return runlog.Record{Type: runlog.TypeArea, Client: client, TimeMs: timeMs, Lat: lat, Lng: lng, RadiusKm: 10, Level: -1}
+}
+
+func TestEvaluate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pubLog, subLog := filepath.Join(dir, "pub.jsonl"), filepath.Join(dir, "sub.jsonl")
+	writeLog(t, pubLog, []runlog.Record{
+		pub("a", 1, 2000, 0, 0, payload.PhaseMeasure),
+		pub("a", 2, 3000, 0, 0, payload.PhaseMeasure),
+		pub("b", 1, 2500, 10, 10, payload.PhaseMeasure),
+		// 受信範囲の設定直後（猶予期間内）
+		pub("a", 3, 1050, 0, 0, payload.PhaseWarmUp),
+		// 受信の終了後
+		pub("a", 4, 6000, 0, 0, payload.PhaseMeasure),
+	})
+	writeLog(t, subLog, []runlog.Record{
+		area(0, 1000, 0, 0),
+		area(1, 0, 10, 10),
+		recv(0, "a", 3, 1060),
+		recv(0, "a", 1, 2010),
+		recv(0, "a", 1, 2020),
+		recv(1, "b", 1, 2550),
+		recv(0, "b", 1, 2600),
+		recv(0, "zz", 1, 2700),
+		{Type: runlog.TypeEnd, Client: 0, TimeMs: 5000},
+	})
+
+	result, err := Evaluate([]string{pubLog}, []string{subLog}, Options{GraceMs: 100, CellLevel: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients := []ClientResult{
+		{Client: subLog + "#0", Expected: 2, Received: 4, Matched: 1, Missed: 1, Unexpected: 1, Ambiguous: 1, Unknown: 1, Duplicate: 1},
+		{Client: subLog + "#1", Expected: 1, Received: 1, Matched: 1},
+		{Client: "total", Expected: 3, Received: 5, Matched: 2, Missed: 1, Unexpected: 1, Ambiguous: 1, Unknown: 1, Duplicate: 1},
+	}
+	got := append(append([]ClientResult{}, result.Clients...), result.Total)
+	if len(got) != len(clients) {
+		t.Fatalf("Clients = %+v, want %v clients", result.Clients, len(clients)-1)
+	}
+	for i, want := range clients {
+		if got[i] != want {
+			t.Errorf("client %v = %+v, want %+v", i, got[i], want)
+		}
+	}
+	if r, p := got[0].Recall(), got[0].Precision(); r != 0.5 || p != 0.5 {
+		t.Errorf("Recall, Precision = %v, %v, want 0.5, 0.5", r, p)
+	}
+
+	// 計測の段階に Publish したメッセージの受信のみを数える
+	if n, min, max := result.Latency.N(), result.Latency.Min(), result.Latency.Max(); n != 4 || min != 10 || max != 100 {
+		t.Errorf("Latency n=%v min=%v max=%v, want 4 10 100", n, min, max)
+	}
+
+	cells := []struct {
+		lat, lng                         float64
+		sent, received, expected, missed int64
+	}{
+		{0, 0, 4, 3, 2, 1},
+		{10, 10, 1, 2, 1, 0},
+	}
+	if len(result.Cells) != len(cells) {
+		t.Fatalf("Cells = %+v, want %v cells", result.Cells, len(cells))
+	}
+	for _, want := range cells {
+		id := s2.CellIDFromLatLng(s2.LatLngFromDegrees(want.lat, want.lng)).Parent(10)
+		found := false
+		for _, c := range result.Cells {
+			if c.Cell != id {
+				continue
+			}
+			found = true
+			if c.Sent != want.sent || c.Received != want.received || c.Expected != want.expected || c.Missed != want.missed {
+				t.Errorf("cell (%v, %v) = %+v, want %+v", want.lat, want.lng, c, want)
+			}
+		}
+		if !found {
+			t.Errorf("cell (%v, %v) is missing", want.lat, want.lng)
+		}
+	}
+}
+
+// 受信半径が 0 以下の受信範囲は、全てのメッセージを受信すべきとみなす
+func TestWholeWorld(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pubLog, subLog := filepath.Join(dir, "pub.jsonl"), filepath.Join(dir, "sub.jsonl")
+	writeLog(t, pubLog, []runlog.Record{
+		pub("a", 1, 2000, 0, 0, payload.PhaseMeasure),
+		pub("a", 2, 2100, 45, 90, payload.PhaseMeasure),
+		pub("a", 3, 2200, -80, -170, payload.PhaseMeasure),
+		// 受信範囲の設定前
+		pub("a", 4, 500, 10, 10, payload.PhaseMeasure),
+	})
+	writeLog(t, subLog, []runlog.Record{
+		{Type: runlog.TypeArea, Client: 0, TimeMs: 1000, Lat: 35, Lng: 139, Level: 10},
+		recv(0, "a", 1, 2010),
+		recv(0, "a", 2, 2110),
+		recv(0, "a", 4, 2120),
+	})
+
+	result, err := Evaluate([]string{pubLog}, []string{subLog}, Options{GraceMs: 100, CellLevel: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ClientResult{Client: "total", Expected: 3, Received: 3, Matched: 2, Missed: 1, Unexpected: 1}
+	if result.Total != want {
+		t.Errorf("Total = %+v, want %+v", result.Total, want)
+	}
+	if p := result.Total.Precision(); p < 0.6 || p > 0.7 {
+		t.Errorf("Precision = %v, want 2/3", p)
+	}
+}
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		c                 ClientResult
+		recall, precision float64
+	}{
+		{ClientResult{}, math.NaN(), math.NaN()},
+		{ClientResult{Expected: 4, Matched: 3, Unexpected: 1}, 0.75, 0.75},
+		// 判定しなかった受信は精度に含めない
+		{ClientResult{Expected: 2, Matched: 2, Ambiguous: 5, Unknown: 1}, 1, 1},
+	}
+	same := func(a, b float64) bool {
+		return a == b || math.IsNaN(a) && math.IsNaN(b)
+	}
+	for _, tt := range tests {
+		if r, p := tt.c.Recall(), tt.c.Precision(); !same(r, tt.recall) || !same(p, tt.precision) {
+			t.Errorf("%+v: Recall, Precision = %v, %v, want %v, %v", tt.c, r, p, tt.recall, tt.precision)
+		}
+	}
+}
+
+func TestMissingLog(t *testing.T) {
+	if _, err := Evaluate([]string{"/nonexistent/pub.jsonl"}, nil, Options{}); err == nil {
+		t.Errorf("Evaluate with a missing log succeeded, want error")
+	}
+}
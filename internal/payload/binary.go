@@ -0,0 +1,101 @@
+package payload
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// binaryMagic は他の符号化方式の先頭バイトと重ならない値（MessagePack では未使用）
+const binaryMagic = 0xC1
+
+func init() {
+	register(binaryCodec{})
+}
+
+// binaryCodec は固定長ヘッダによる符号化
+// マジックナンバー(1) + スキーマバージョン(1) + 各フィールド + Padding（残り全て）の形式
+// 文字列は長さ(1) + 本体（255 バイトまで）、整数・浮動小数点数は 8 バイトのビッグエンディアン
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string {
+	return "binary"
+}
+
+func (binaryCodec) Encode(m Measurement, size int) []byte {
+	b := []byte{binaryMagic, schemaVersion}
+	for _, f := range schema {
+		v := m.get(f)
+		switch f.kind {
+		case kindString:
+			s := v.s
+			if len(s) > 255 {
+				s = s[:255]
+			}
+			b = append(b, byte(len(s)))
+			b = append(b, s...)
+		case kindInt:
+			b = appendUint64(b, uint64(v.i))
+		case kindFloat:
+			b = appendUint64(b, math.Float64bits(v.f))
+		}
+	}
+	return append(b, fill(m.Padding, size-len(b))...)
+}
+
+func (binaryCodec) Decode(b []byte) (Measurement, error) {
+	r := &reader{b: b}
+	head, err := r.next(2)
+	if err != nil {
+		return Measurement{}, err
+	}
+	if head[0] != binaryMagic {
+		return Measurement{}, PayloadError{"Invalid magic number"}
+	}
+	if head[1] != schemaVersion {
+		return Measurement{}, PayloadError{"Unsupported schema version"}
+	}
+	m := Measurement{}
+	for _, f := range schema {
+		switch f.kind {
+		case kindString:
+			n, err := r.byte()
+			if err != nil {
+				return Measurement{}, err
+			}
+			s, err := r.next(int(n))
+			if err != nil {
+				return Measurement{}, err
+			}
+			m.set(f, value{s: string(s)})
+		case kindInt:
+			i, err := r.next(8)
+			if err != nil {
+				return Measurement{}, err
+			}
+			m.set(f, value{i: int64(binary.BigEndian.Uint64(i))})
+		case kindFloat:
+			x, err := r.next(8)
+			if err != nil {
+				return Measurement{}, err
+			}
+			m.set(f, value{f: math.Float64frombits(binary.BigEndian.Uint64(x))})
+		}
+	}
+	return m, nil
+}
+
+func (binaryCodec) detect(b []byte) bool {
+	return len(b) > 0 && b[0] == binaryMagic
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return append(b, buf...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, v)
+	return append(b, buf...)
+}
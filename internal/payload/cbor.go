@@ -0,0 +1,156 @@
+package payload
+
+import "math"
+
+// CBOR のメジャータイプ
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborMap    = 5
+	cborSimple = 7
+)
+
+func init() {
+	register(cborCodec{})
+}
+
+// cborCodec は CBOR による符号化
+// キーをフィールド名とする map として書き込む
+// 指定サイズちょうどにするため、整数・浮動小数点数は常に 8 バイト、Padding の長さは常に 4 バイトで表現する
+type cborCodec struct{}
+
+func (cborCodec) Name() string {
+	return "cbor"
+}
+
+func (cborCodec) Encode(m Measurement, size int) []byte {
+	b := []byte{cborMap<<5 | byte(len(schema)+1)}
+	for _, f := range schema {
+		b = appendCborText(b, f.key)
+		v := m.get(f)
+		switch f.kind {
+		case kindString:
+			s := v.s
+			if len(s) > 255 {
+				s = s[:255]
+			}
+			b = append(b, cborText<<5|24, byte(len(s)))
+			b = append(b, s...)
+		case kindInt:
+			if v.i < 0 {
+				b = append(b, cborNegInt<<5|27)
+				b = appendUint64(b, uint64(-1-v.i))
+			} else {
+				b = append(b, cborUint<<5|27)
+				b = appendUint64(b, uint64(v.i))
+			}
+		case kindFloat:
+			b = append(b, cborSimple<<5|27)
+			b = appendUint64(b, math.Float64bits(v.f))
+		}
+	}
+	b = appendCborText(b, paddingField.key)
+	b = append(b, cborText<<5|26)
+	n := size - len(b) - 4
+	if n < 0 {
+		n = 0
+	}
+	b = appendUint32(b, uint32(n))
+	return append(b, fill(m.Padding, n)...)
+}
+
+func appendCborText(b []byte, s string) []byte {
+	b = append(b, cborText<<5|byte(len(s)))
+	return append(b, s...)
+}
+
+func (cborCodec) Decode(b []byte) (Measurement, error) {
+	r := &reader{b: b}
+	major, _, n, err := readCborHead(r)
+	if err != nil {
+		return Measurement{}, err
+	}
+	if major != cborMap {
+		return Measurement{}, PayloadError{"CBOR payload is not a map"}
+	}
+	m := Measurement{}
+	for i := uint64(0); i < n; i++ {
+		key, err := readCbor(r)
+		if err != nil {
+			return Measurement{}, err
+		}
+		v, err := readCbor(r)
+		if err != nil {
+			return Measurement{}, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			continue
+		}
+		setDecoded(&m, k, v)
+	}
+	return m, nil
+}
+
+func (cborCodec) detect(b []byte) bool {
+	return len(b) > 0 && b[0]>>5 == cborMap
+}
+
+// readCborHead はメジャータイプ、追加情報、引数を読み出す（不定長は未対応）
+func readCborHead(r *reader) (byte, byte, uint64, error) {
+	c, err := r.byte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info := c>>5, c&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		b, err := r.next(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		v := uint64(0)
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return major, info, v, nil
+	}
+	return 0, 0, 0, PayloadError{"Unsupported CBOR length"}
+}
+
+// readCbor は CBOR の値を 1 つ読み出す
+// 戻り値は string, int64, float64, bool, nil のいずれか（配列・map・タグは未対応）
+func readCbor(r *reader) (interface{}, error) {
+	major, info, v, err := readCborHead(r)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return int64(v), nil
+	case cborNegInt:
+		return -1 - int64(v), nil
+	case cborBytes, cborText:
+		s, err := r.next(int(v))
+		return string(s), err
+	case cborSimple:
+		// 浮動小数点数は引数として読み出したビット列をそのまま解釈する
+		switch {
+		case info == 26:
+			return float64(math.Float32frombits(uint32(v))), nil
+		case info == 27:
+			return math.Float64frombits(v), nil
+		case v == 20:
+			return false, nil
+		case v == 21:
+			return true, nil
+		case v == 22 || v == 23:
+			return nil, nil
+		}
+	}
+	return nil, PayloadError{"Unsupported CBOR type"}
+}
@@ -0,0 +1,75 @@
+package payload
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+func init() {
+	register(jsonCodec{})
+}
+
+// jsonCodec は JSON による符号化
+// {"i":"...","t":...,"p":"..."} の形式（optional のフィールドは値が 0 の場合に省略する）
+// メッセージごとに長さが変わらないよう、数値はフィールドごとの桁数に揃え（緯度・経度は小数点以下 floatDigits 桁）、足りない桁は値の前の空白で埋める
+type jsonCodec struct{}
+
+// floatDigits は緯度・経度の小数点以下の桁数（1e-7 度は約 1cm）
+const floatDigits = 7
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Encode(m Measurement, size int) []byte {
+	b := []byte{'{'}
+	for _, f := range schema {
+		v := m.get(f)
+		if f.optional && v.i == 0 {
+			continue
+		}
+		b = appendJSONString(b, f.key)
+		b = append(b, ':')
+		switch f.kind {
+		case kindString:
+			b = appendJSONString(b, v.s)
+		case kindInt:
+			b = appendAligned(b, strconv.AppendInt(nil, v.i, 10), f.width)
+		case kindFloat:
+			b = appendAligned(b, strconv.AppendFloat(nil, v.f, 'f', floatDigits, 64), f.width)
+		}
+		b = append(b, ',')
+	}
+	b = appendJSONString(b, paddingField.key)
+	b = append(b, ':', '"')
+	// 詰め物はエスケープされない文字のみを想定しているため、追加した文字数だけ長くなる
+	b = append(b, fill(m.Padding, size-len(b)-2)...)
+	return append(b, '"', '}')
+}
+
+func (jsonCodec) Decode(b []byte) (Measurement, error) {
+	var m Measurement
+	if err := json.Unmarshal(b, &m); err != nil {
+		return Measurement{}, err
+	}
+	m.Padding = ""
+	return m, nil
+}
+
+func (jsonCodec) detect(b []byte) bool {
+	return len(b) > 0 && b[0] == '{'
+}
+
+func appendJSONString(b []byte, s string) []byte {
+	// 文字列は JSON に変換できない値を含まないため、エラーは発生しない
+	q, _ := json.Marshal(s)
+	return append(b, q...)
+}
+
+// appendAligned は v が width 桁に満たない場合に前を空白で埋めて書き込む
+func appendAligned(b, v []byte, width int) []byte {
+	for i := len(v); i < width; i++ {
+		b = append(b, ' ')
+	}
+	return append(b, v...)
+}
@@ -0,0 +1,219 @@
+package payload
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+func init() {
+	register(msgpackCodec{})
+}
+
+// msgpackCodec は MessagePack による符号化
+// キーをフィールド名とする map として書き込む
+// 指定サイズちょうどにするため、整数は常に int64、浮動小数点数は常に float64、Padding は常に str32 で表現する
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (msgpackCodec) Encode(m Measurement, size int) []byte {
+	b := []byte{0x80 | byte(len(schema)+1)}
+	for _, f := range schema {
+		b = appendMsgpackKey(b, f.key)
+		v := m.get(f)
+		switch f.kind {
+		case kindString:
+			s := v.s
+			if len(s) > 255 {
+				s = s[:255]
+			}
+			b = append(b, 0xd9, byte(len(s)))
+			b = append(b, s...)
+		case kindInt:
+			b = append(b, 0xd3)
+			b = appendUint64(b, uint64(v.i))
+		case kindFloat:
+			b = append(b, 0xcb)
+			b = appendUint64(b, math.Float64bits(v.f))
+		}
+	}
+	b = appendMsgpackKey(b, paddingField.key)
+	b = append(b, 0xdb)
+	n := size - len(b) - 4
+	if n < 0 {
+		n = 0
+	}
+	b = appendUint32(b, uint32(n))
+	return append(b, fill(m.Padding, n)...)
+}
+
+func appendMsgpackKey(b []byte, key string) []byte {
+	b = append(b, 0xa0|byte(len(key)))
+	return append(b, key...)
+}
+
+func (msgpackCodec) Decode(b []byte) (Measurement, error) {
+	r := &reader{b: b}
+	head, err := r.byte()
+	if err != nil {
+		return Measurement{}, err
+	}
+	var n int
+	switch {
+	case head&0xf0 == 0x80:
+		n = int(head & 0x0f)
+	case head == 0xde:
+		l, err := r.next(2)
+		if err != nil {
+			return Measurement{}, err
+		}
+		n = int(binary.BigEndian.Uint16(l))
+	case head == 0xdf:
+		l, err := r.next(4)
+		if err != nil {
+			return Measurement{}, err
+		}
+		n = int(binary.BigEndian.Uint32(l))
+	default:
+		return Measurement{}, PayloadError{"MessagePack payload is not a map"}
+	}
+	m := Measurement{}
+	for i := 0; i < n; i++ {
+		key, err := readMsgpack(r)
+		if err != nil {
+			return Measurement{}, err
+		}
+		v, err := readMsgpack(r)
+		if err != nil {
+			return Measurement{}, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			continue
+		}
+		setDecoded(&m, k, v)
+	}
+	return m, nil
+}
+
+func (msgpackCodec) detect(b []byte) bool {
+	return len(b) > 0 && (b[0]&0xf0 == 0x80 || b[0] == 0xde || b[0] == 0xdf)
+}
+
+// readMsgpack は MessagePack の値を 1 つ読み出す
+// 戻り値は string, int64, float64, bool, nil のいずれか（配列・map は未対応）
+func readMsgpack(r *reader) (interface{}, error) {
+	c, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		s, err := r.next(int(c & 0x1f))
+		return string(s), err
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		return readMsgpackString(r, 1)
+	case 0xc5, 0xda:
+		return readMsgpackString(r, 2)
+	case 0xc6, 0xdb:
+		return readMsgpackString(r, 4)
+	case 0xca:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xd0:
+		b, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xd0 {
+			return int64(int8(b[0])), nil
+		}
+		return int64(b[0]), nil
+	case 0xcd, 0xd1:
+		b, err := r.next(2)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xd1 {
+			return int64(int16(binary.BigEndian.Uint16(b))), nil
+		}
+		return int64(binary.BigEndian.Uint16(b)), nil
+	case 0xce, 0xd2:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xd2 {
+			return int64(int32(binary.BigEndian.Uint32(b))), nil
+		}
+		return int64(binary.BigEndian.Uint32(b)), nil
+	case 0xcf, 0xd3:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	}
+	return nil, PayloadError{"Unsupported MessagePack type"}
+}
+
+func readMsgpackString(r *reader, width int) (interface{}, error) {
+	l, err := r.next(width)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, c := range l {
+		n = n<<8 | int(c)
+	}
+	s, err := r.next(n)
+	return string(s), err
+}
+
+// setDecoded は自己記述的な形式で復号した値を m のフィールドへ設定する
+func setDecoded(m *Measurement, key string, v interface{}) {
+	f, ok := lookupField(key)
+	if !ok || f.key == paddingField.key {
+		return
+	}
+	switch f.kind {
+	case kindString:
+		if s, ok := v.(string); ok {
+			m.set(f, value{s: s})
+		}
+	case kindInt:
+		if i, ok := v.(int64); ok {
+			m.set(f, value{i: i})
+		}
+	case kindFloat:
+		switch x := v.(type) {
+		case float64:
+			m.set(f, value{f: x})
+		case int64:
+			m.set(f, value{f: float64(x)})
+		}
+	}
+}
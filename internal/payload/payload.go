@@ -0,0 +1,259 @@
+// Package payload は計測用メッセージの符号化・復号を行う
+package payload
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Measurement は計測用メッセージの内容
+type Measurement struct {
+	// メッセージを小さく保つため、JSON のキーは短い名前とする（スキーマの key と同じ。緯度は y、経度は x）
+	ID     string `json:"i"`
+	TimeMs int64  `json:"t"`
+	// Seq は Publisher のプロセス内で一意な通し番号
+	Seq int64 `json:"s"`
+	// Lat, Lng は Publish した位置（度）
+	Lat float64 `json:"y"`
+	Lng float64 `json:"x"`
+	// Phase は Publish した時点の計測の段階（Subscriber 側でも同じ区切りで集計から除くのに使う）
+	Phase int `json:"h,omitempty"`
+	// BurstMs はバースト送信で Publish した場合に、バーストの予定時刻[ms]（Subscriber 側でバーストごとに集計するのに使う。それ以外は 0）
+	BurstMs int64 `json:"b,omitempty"`
+	// Padding は符号化時に指定サイズへ揃えるための詰め物
+	// 符号化時には詰め物の元となる文字列として使われ、必要な長さだけ先頭から（足りなければ繰り返して）使われる
+	Padding string `json:"p"`
+}
+
+// 計測の段階
+const (
+	// PhaseMeasure は集計の対象とする段階
+	PhaseMeasure = iota
+	// PhaseWarmUp はクライアントの接続・Subscribe が揃うまでの段階（記録するが集計から除く）
+	PhaseWarmUp
+	// PhaseCoolDown は送信中のメッセージが届ききるまでの段階（記録するが集計から除く）
+	PhaseCoolDown
+	// Phases は段階の数
+	Phases
+)
+
+var phaseNames = [Phases]string{"measure", "warm-up", "cool-down"}
+
+// PhaseName は段階の名前を返す
+func PhaseName(phase int) string {
+	if phase < 0 || phase >= Phases {
+		return fmt.Sprintf("unknown(%v)", phase)
+	}
+	return phaseNames[phase]
+}
+
+// 値の種類
+const (
+	kindString = iota
+	kindInt
+	kindFloat
+)
+
+// field はスキーマ上の 1 フィールド
+type field struct {
+	number int
+	key    string
+	kind   int
+	// width はテキスト系の符号化で数値を書く際の最大の桁数（符号・小数点を含む）
+	// 値によらず長さが変わらないよう、足りない桁は値の前の空白で埋める
+	width int
+	// optional はテキスト系の符号化で値が 0 の場合に省略することを表す（使わない計測では長さに含めない）
+	optional bool
+}
+
+// schema は Padding 以外のフィールドの定義（バイナリ系の符号化はこの順で書き込む）
+var schema = []field{
+	{number: 1, key: "i", kind: kindString},
+	{number: 2, key: "t", kind: kindInt, width: 13},
+	{number: 3, key: "y", kind: kindFloat, width: 11},
+	{number: 4, key: "x", kind: kindFloat, width: 12},
+	{number: 5, key: "s", kind: kindInt, width: 10},
+	{number: 6, key: "h", kind: kindInt, width: 1, optional: true},
+	{number: 7, key: "b", kind: kindInt, width: 13, optional: true},
+}
+
+// paddingField は Padding のフィールド定義（常に最後に書き込む）
+var paddingField = field{number: 15, key: "p", kind: kindString}
+
+// schemaVersion はスキーマを変更した際に更新する
+const schemaVersion = 7
+
+// value はフィールドの値
+type value struct {
+	s string
+	i int64
+	f float64
+}
+
+func (m *Measurement) get(f field) value {
+	switch f.key {
+	case "i":
+		return value{s: m.ID}
+	case "t":
+		return value{i: m.TimeMs}
+	case "y":
+		return value{f: m.Lat}
+	case "x":
+		return value{f: m.Lng}
+	case "s":
+		return value{i: m.Seq}
+	case "h":
+		return value{i: int64(m.Phase)}
+	case "b":
+		return value{i: m.BurstMs}
+	case "p":
+		return value{s: m.Padding}
+	}
+	return value{}
+}
+
+func (m *Measurement) set(f field, v value) {
+	switch f.key {
+	case "i":
+		m.ID = v.s
+	case "t":
+		m.TimeMs = v.i
+	case "y":
+		m.Lat = v.f
+	case "x":
+		m.Lng = v.f
+	case "s":
+		m.Seq = v.i
+	case "h":
+		m.Phase = int(v.i)
+	case "b":
+		m.BurstMs = v.i
+	case "p":
+		m.Padding = v.s
+	}
+}
+
+func lookupField(key string) (field, bool) {
+	if key == paddingField.key {
+		return paddingField, true
+	}
+	for _, f := range schema {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func lookupNumber(number int) (field, bool) {
+	if number == paddingField.number {
+		return paddingField, true
+	}
+	for _, f := range schema {
+		if f.number == number {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Codec はメッセージの符号化方式
+type Codec interface {
+	// Name は符号化方式の名前を返す
+	Name() string
+	// Encode は m を size バイトになるように Padding を詰めて符号化する
+	// size が Padding 無しの長さより小さい場合は Padding 無しで符号化する
+	Encode(m Measurement, size int) []byte
+	// Decode は b を復号する（Padding は復号しない）
+	Decode(b []byte) (Measurement, error)
+	// detect は b がこの符号化方式で符号化されていそうかを先頭バイトから判定する
+	detect(b []byte) bool
+}
+
+var codecs = map[string]Codec{}
+
+func register(c Codec) {
+	codecs[c.Name()] = c
+}
+
+// Names は利用可能な符号化方式の名前を返す
+func Names() []string {
+	names := []string{}
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup は名前から符号化方式を返す
+func Lookup(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, PayloadError{fmt.Sprintf("Unknown codec: %v (available: %v)", name, strings.Join(Names(), ", "))}
+	}
+	return c, nil
+}
+
+// Decode は符号化方式を自動判定して b を復号する
+func Decode(b []byte) (Measurement, Codec, error) {
+	for _, name := range Names() {
+		c := codecs[name]
+		if c.detect(b) {
+			m, err := c.Decode(b)
+			return m, c, err
+		}
+	}
+	return Measurement{}, nil, PayloadError{"Unknown payload format"}
+}
+
+// fill は src を繰り返して n 文字の詰め物を作る
+func fill(src string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if src == "" {
+		src = "a"
+	}
+	if len(src) >= n {
+		return src[:n]
+	}
+	return strings.Repeat(src, n/len(src)+1)[:n]
+}
+
+// PayloadError は符号化・復号時のエラー
+type PayloadError struct {
+	Msg string
+}
+
+func (e PayloadError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
+
+// reader は範囲外の読み出しを検出しながらバイト列を読み進める
+type reader struct {
+	b   []byte
+	pos int
+}
+
+func (r *reader) remaining() int {
+	return len(r.b) - r.pos
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || r.remaining() < n {
+		return nil, PayloadError{"Truncated payload"}
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) byte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
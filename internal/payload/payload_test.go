@@ -0,0 +1,169 @@
+package payload
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var measurements = []struct {
+	name string
+	m    Measurement
+}{
+	{"zero", Measurement{}},
+	{"typical", Measurement{ID: "kXcyoZJpIL", TimeMs: 1760000000123, Seq: 42, Lat: 35.681167, Lng: 139.767052}},
+	{"negative", Measurement{ID: "a", TimeMs: 1, Seq: 1, Lat: -33.8688197, Lng: -151.2092955}},
+	{"extreme", Measurement{ID: "s99-r10000", TimeMs: 9999999999999, Seq: 9999999999, Lat: -90, Lng: -180}},
+	{"phase", Measurement{ID: "id", TimeMs: 1760000000123, Seq: 7, Lat: 1.5, Lng: 2.5, Phase: PhaseCoolDown}},
+	{"burst", Measurement{ID: "id", TimeMs: 1760000000123, Seq: 7, Lat: 1.5, Lng: 2.5, Phase: PhaseWarmUp, BurstMs: 1760000001000}},
+	{"escaped ID", Measurement{ID: `a"b\c`, TimeMs: 3, Seq: 4, Lat: 5, Lng: 6}},
+}
+
+func TestCodecs(t *testing.T) {
+	want := []string{"binary", "cbor", "json", "msgpack", "protobuf"}
+	if got := Names(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Names = %v, want %v", got, want)
+	}
+	if _, err := Lookup("xml"); err == nil {
+		t.Errorf("Lookup(xml) succeeded, want error")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, name := range Names() {
+		c, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range measurements {
+			min := len(c.Encode(tt.m, 0))
+			for _, size := range []int{0, min, min + 1, 1000} {
+				m := tt.m
+				m.Padding = "xyz"
+				b := c.Encode(m, size)
+				got, err := c.Decode(b)
+				if err != nil {
+					t.Errorf("%v %v size=%v: Decode: %v", name, tt.name, size, err)
+					continue
+				}
+				if got != tt.m {
+					t.Errorf("%v %v size=%v: Decode = %+v, want %+v", name, tt.name, size, got, tt.m)
+				}
+				// 符号化方式を自動判定しても同じ結果になる
+				got, detected, err := Decode(b)
+				if err != nil || detected.Name() != name || got != tt.m {
+					t.Errorf("%v %v size=%v: Decode (auto) = %+v, %v, %v", name, tt.name, size, got, detected, err)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, name := range Names() {
+		c, _ := Lookup(name)
+		b := c.Encode(measurements[1].m, 0)
+		// 空のメッセージは protobuf では全て既定値のメッセージとして正しいため含めない
+		for _, n := range []int{1, len(b) / 2, len(b) - 1} {
+			if _, err := c.Decode(b[:n]); err == nil {
+				t.Errorf("%v: Decode of %v/%v bytes succeeded, want error", name, n, len(b))
+			}
+		}
+	}
+	if _, _, err := Decode([]byte("hello")); err == nil {
+		t.Errorf("Decode(hello) succeeded, want error")
+	}
+}
+
+func TestParseSizeDist(t *testing.T) {
+	tests := []struct {
+		spec     string
+		ok       bool
+		min, max int
+	}{
+		{"fixed:100", true, 100, 100},
+		{"uniform:100-300", true, 100, 300},
+		{"weighted:512=2,128=1", true, 128, 512},
+		{"lognormal:200,0.5", true, 0, 1 << 20},
+		{"lognormal:200,0.5,120,1000", true, 120, 1000},
+		{"fixed:-1", false, 0, 0},
+		{"uniform:300-100", false, 0, 0},
+		{"uniform:100", false, 0, 0},
+		{"weighted:128=0", false, 0, 0},
+		{"weighted:128", false, 0, 0},
+		{"lognormal:0,1", false, 0, 0},
+		{"lognormal:200,0.5,300,100", false, 0, 0},
+		{"normal:100", false, 0, 0},
+		{"", false, 0, 0},
+	}
+	for _, tt := range tests {
+		d, err := ParseSizeDist(tt.spec)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("ParseSizeDist(%q): err = %v, want ok = %v", tt.spec, err, tt.ok)
+			continue
+		}
+		if tt.ok && (d.Min() != tt.min || d.Max() != tt.max) {
+			t.Errorf("ParseSizeDist(%q): range = [%v, %v], want [%v, %v]", tt.spec, d.Min(), d.Max(), tt.min, tt.max)
+		}
+	}
+}
+
+func TestExactSize(t *testing.T) {
+	specs := []string{"fixed:100", "fixed:4096", "uniform:120-300", "weighted:128=1,512=2,1500=1", "lognormal:200,1,120,2000"}
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range Names() {
+		c, _ := Lookup(name)
+		for _, spec := range specs {
+			d, err := ParseSizeDist(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 1000; i++ {
+				// 数値の桁数や符号が変わっても長さが変わらないことを確かめる
+				m := Measurement{
+					ID:      "kXcyoZJpIL",
+					TimeMs:  rng.Int63n(10000000000000),
+					Seq:     rng.Int63n(10000000000),
+					Lat:     rng.Float64()*180 - 90,
+					Lng:     rng.Float64()*360 - 180,
+					Padding: "0123456789",
+				}
+				if i%2 == 1 {
+					m.Phase = rng.Intn(Phases)
+				}
+				n := d.Next(rng)
+				if n < d.Min() || n > d.Max() {
+					t.Fatalf("%v: Next = %v, out of [%v, %v]", spec, n, d.Min(), d.Max())
+				}
+				if min := MinSize(c, m); n < min {
+					t.Fatalf("%v %v: size %v is below the minimum %v", name, spec, n, min)
+				}
+				if got := len(c.Encode(m, n)); got != n {
+					t.Errorf("%v %v: len(Encode(%+v, %v)) = %v", name, spec, m, n, got)
+				}
+			}
+		}
+	}
+}
+
+func TestMinSize(t *testing.T) {
+	for _, name := range Names() {
+		c, _ := Lookup(name)
+		for _, tt := range measurements {
+			m := tt.m
+			m.ID = "kXcyoZJpIL"
+			min := MinSize(c, m)
+			if got := len(c.Encode(m, 0)); got > min {
+				t.Errorf("%v %v: len(Encode(m, 0)) = %v, want <= MinSize %v", name, tt.name, got, min)
+			}
+			// 最小より小さいサイズを指定した場合は、詰め物無しで符号化する
+			if got := len(c.Encode(m, 1)); got != len(c.Encode(m, 0)) {
+				t.Errorf("%v %v: len(Encode(m, 1)) = %v, want %v", name, tt.name, got, len(c.Encode(m, 0)))
+			}
+		}
+		// 既定の -msglen は既定の 10 文字の ID で計測の段階を含めても収まる
+		if min := MinSize(c, Measurement{ID: "kXcyoZJpIL", Phase: PhaseWarmUp}); min > 100 {
+			t.Errorf("%v: MinSize with phase = %v, want <= 100", name, min)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package payload
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Protocol Buffers のワイヤタイプ
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func init() {
+	register(protobufCodec{})
+}
+
+// protobufCodec は Protocol Buffers 互換の符号化
+// フィールド番号はスキーマの number を使い、Padding は最後に bytes 型として書き込む
+// メッセージごとに長さが変わらないよう、整数は varint ではなく 8 バイト固定長（sfixed64）で書き込む
+// Padding の長さは指定サイズちょうどになるよう、必要に応じて冗長な varint で表現する
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (protobufCodec) Encode(m Measurement, size int) []byte {
+	b := []byte{}
+	for _, f := range schema {
+		v := m.get(f)
+		switch f.kind {
+		case kindString:
+			b = appendVarint(b, uint64(f.number<<3|wireBytes))
+			b = appendVarint(b, uint64(len(v.s)))
+			b = append(b, v.s...)
+		case kindInt:
+			b = appendVarint(b, uint64(f.number<<3|wireFixed64))
+			b = appendFixed64(b, uint64(v.i))
+		case kindFloat:
+			b = appendVarint(b, uint64(f.number<<3|wireFixed64))
+			b = appendFixed64(b, math.Float64bits(v.f))
+		}
+	}
+	tag := appendVarint([]byte{}, uint64(paddingField.number<<3|wireBytes))
+	width := len(appendVarint([]byte{}, uint64(size)))
+	n := size - len(b) - len(tag) - width
+	if n < 0 {
+		return b
+	}
+	b = append(b, tag...)
+	b = appendPaddedVarint(b, uint64(n), width)
+	return append(b, fill(m.Padding, n)...)
+}
+
+func (protobufCodec) Decode(b []byte) (Measurement, error) {
+	r := &reader{b: b}
+	m := Measurement{}
+	for r.remaining() > 0 {
+		tag, err := readVarint(r)
+		if err != nil {
+			return Measurement{}, err
+		}
+		f, known := lookupNumber(int(tag >> 3))
+		switch tag & 7 {
+		case wireVarint:
+			i, err := readVarint(r)
+			if err != nil {
+				return Measurement{}, err
+			}
+			if known && f.kind == kindInt {
+				m.set(f, value{i: int64(i)})
+			}
+		case wireFixed64:
+			x, err := r.next(8)
+			if err != nil {
+				return Measurement{}, err
+			}
+			switch {
+			case known && f.kind == kindFloat:
+				m.set(f, value{f: math.Float64frombits(binary.LittleEndian.Uint64(x))})
+			case known && f.kind == kindInt:
+				m.set(f, value{i: int64(binary.LittleEndian.Uint64(x))})
+			}
+		case wireBytes:
+			n, err := readVarint(r)
+			if err != nil {
+				return Measurement{}, err
+			}
+			s, err := r.next(int(n))
+			if err != nil {
+				return Measurement{}, err
+			}
+			if known && f.kind == kindString && f.key != paddingField.key {
+				m.set(f, value{s: string(s)})
+			}
+		case wireFixed32:
+			if _, err := r.next(4); err != nil {
+				return Measurement{}, err
+			}
+		default:
+			return Measurement{}, PayloadError{"Unsupported wire type"}
+		}
+	}
+	return m, nil
+}
+
+func (protobufCodec) detect(b []byte) bool {
+	// 先頭は必ずフィールド番号 1 の文字列（ID）
+	return len(b) > 0 && b[0] == byte(schema[0].number<<3|wireBytes)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	x := make([]byte, 8)
+	binary.LittleEndian.PutUint64(x, v)
+	return append(b, x...)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+// appendPaddedVarint は v を width バイトの（冗長な）varint として書き込む
+func appendPaddedVarint(b []byte, v uint64, width int) []byte {
+	for i := 0; i < width-1; i++ {
+		b = append(b, byte(v&0x7f)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v&0x7f))
+}
+
+func readVarint(r *reader) (uint64, error) {
+	v := uint64(0)
+	for shift := uint(0); shift < 64; shift += 7 {
+		c, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, PayloadError{"Varint overflow"}
+}
@@ -0,0 +1,252 @@
+package payload
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SizeDist はメッセージサイズ[byte]の分布
+type SizeDist interface {
+	// Next は rng を使って次のメッセージサイズを返す
+	Next(rng *rand.Rand) int
+	// Min, Max は取り得るサイズの範囲を返す
+	Min() int
+	Max() int
+	String() string
+}
+
+// ParseSizeDist は分布の指定を解釈する
+//
+//	fixed:<size>
+//	uniform:<min>-<max>
+//	weighted:<size>=<weight>,<size>=<weight>,...
+//	lognormal:<median>,<sigma>[,<min>,<max>]
+func ParseSizeDist(spec string) (SizeDist, error) {
+	kind, args := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, args = spec[:i], spec[i+1:]
+	}
+	switch kind {
+	case "fixed":
+		size, err := strconv.Atoi(args)
+		if err != nil || size < 0 {
+			return nil, sizeDistError(spec)
+		}
+		return fixedSize(size), nil
+	case "uniform":
+		r := strings.SplitN(args, "-", 2)
+		if len(r) != 2 {
+			return nil, sizeDistError(spec)
+		}
+		min, err1 := strconv.Atoi(r[0])
+		max, err2 := strconv.Atoi(r[1])
+		if err1 != nil || err2 != nil || min < 0 || max < min {
+			return nil, sizeDistError(spec)
+		}
+		return uniformSize{min: min, max: max}, nil
+	case "weighted":
+		w := weightedSize{}
+		for _, item := range strings.Split(args, ",") {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return nil, sizeDistError(spec)
+			}
+			size, err1 := strconv.Atoi(kv[0])
+			weight, err2 := strconv.ParseFloat(kv[1], 64)
+			if err1 != nil || err2 != nil || size < 0 || weight <= 0 {
+				return nil, sizeDistError(spec)
+			}
+			w.sizes = append(w.sizes, size)
+			w.cumulative = append(w.cumulative, w.total+weight)
+			w.total += weight
+		}
+		return w, nil
+	case "lognormal":
+		params := strings.Split(args, ",")
+		if len(params) != 2 && len(params) != 4 {
+			return nil, sizeDistError(spec)
+		}
+		median, err1 := strconv.ParseFloat(params[0], 64)
+		sigma, err2 := strconv.ParseFloat(params[1], 64)
+		if err1 != nil || err2 != nil || median <= 0 || sigma < 0 {
+			return nil, sizeDistError(spec)
+		}
+		l := logNormalSize{mu: math.Log(median), sigma: sigma, min: 0, max: 1 << 20}
+		if len(params) == 4 {
+			min, err1 := strconv.Atoi(params[2])
+			max, err2 := strconv.Atoi(params[3])
+			if err1 != nil || err2 != nil || min < 0 || max < min {
+				return nil, sizeDistError(spec)
+			}
+			l.min, l.max = min, max
+		}
+		return l, nil
+	}
+	return nil, sizeDistError(spec)
+}
+
+func sizeDistError(spec string) error {
+	return PayloadError{fmt.Sprintf("Invalid size distribution (inputed: %v)", spec)}
+}
+
+type fixedSize int
+
+func (s fixedSize) Next(rng *rand.Rand) int { return int(s) }
+func (s fixedSize) Min() int                { return int(s) }
+func (s fixedSize) Max() int                { return int(s) }
+func (s fixedSize) String() string          { return fmt.Sprintf("fixed:%v", int(s)) }
+
+type uniformSize struct {
+	min, max int
+}
+
+func (s uniformSize) Next(rng *rand.Rand) int { return s.min + rng.Intn(s.max-s.min+1) }
+func (s uniformSize) Min() int                { return s.min }
+func (s uniformSize) Max() int                { return s.max }
+func (s uniformSize) String() string          { return fmt.Sprintf("uniform:%v-%v", s.min, s.max) }
+
+type weightedSize struct {
+	sizes      []int
+	cumulative []float64
+	total      float64
+}
+
+func (s weightedSize) Next(rng *rand.Rand) int {
+	x := rng.Float64() * s.total
+	i := sort.SearchFloat64s(s.cumulative, x)
+	if i >= len(s.sizes) {
+		i = len(s.sizes) - 1
+	}
+	return s.sizes[i]
+}
+
+func (s weightedSize) Min() int {
+	min := s.sizes[0]
+	for _, size := range s.sizes {
+		if size < min {
+			min = size
+		}
+	}
+	return min
+}
+
+func (s weightedSize) Max() int {
+	max := s.sizes[0]
+	for _, size := range s.sizes {
+		if size > max {
+			max = size
+		}
+	}
+	return max
+}
+
+func (s weightedSize) String() string {
+	items := []string{}
+	prev := 0.
+	for i, size := range s.sizes {
+		items = append(items, fmt.Sprintf("%v=%v", size, s.cumulative[i]-prev))
+		prev = s.cumulative[i]
+	}
+	return "weighted:" + strings.Join(items, ",")
+}
+
+// logNormalSize は対数正規分布に従うサイズを [min, max] に丸めて返す
+type logNormalSize struct {
+	mu, sigma float64
+	min, max  int
+}
+
+func (s logNormalSize) Next(rng *rand.Rand) int {
+	size := int(math.Round(math.Exp(s.mu + s.sigma*rng.NormFloat64())))
+	if size < s.min {
+		return s.min
+	}
+	if size > s.max {
+		return s.max
+	}
+	return size
+}
+
+func (s logNormalSize) Min() int { return s.min }
+func (s logNormalSize) Max() int { return s.max }
+func (s logNormalSize) String() string {
+	return fmt.Sprintf("lognormal:%v,%v,%v,%v", math.Exp(s.mu), s.sigma, s.min, s.max)
+}
+
+// MinSize は m と同じ ID のメッセージを c で符号化した際の、Padding 無しの最大の長さを返す
+// 時刻、通し番号、位置は最も長くなる値（符号・桁数が最大の値）で求める
+// 計測の段階、バーストの予定時刻は、m で 0 でない場合のみ使うものとして含める
+// これより小さいサイズは指定しても正確に再現できない
+func MinSize(c Codec, m Measurement) int {
+	m.TimeMs, m.Seq, m.Lat, m.Lng, m.Padding = 9999999999999, 9999999999, -90, -180, ""
+	if m.Phase != 0 {
+		m.Phase = Phases - 1
+	}
+	if m.BurstMs != 0 {
+		m.BurstMs = 9999999999999
+	}
+	return len(c.Encode(m, 0))
+}
+
+// SizeBuckets は受信したメッセージのサイズを区分するための境界値
+type SizeBuckets []int
+
+// ParseSizeBuckets は昇順の境界値をカンマ区切りで解釈する
+// 空文字列の場合は 2 のべき乗で区分する
+func ParseSizeBuckets(spec string) (SizeBuckets, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	b := SizeBuckets{}
+	for _, v := range strings.Split(spec, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 || (len(b) > 0 && n <= b[len(b)-1]) {
+			return nil, PayloadError{fmt.Sprintf("Invalid size buckets (inputed: %v)", spec)}
+		}
+		b = append(b, n)
+	}
+	return b, nil
+}
+
+// Bucket は size が属する区分の下限と上限（上限は含まない、-1 は上限なし）を返す
+func (b SizeBuckets) Bucket(size int) (int, int) {
+	if len(b) == 0 {
+		lower := 0
+		for upper := 1; ; upper <<= 1 {
+			if size < upper {
+				return lower, upper
+			}
+			lower = upper
+		}
+	}
+	lower := 0
+	for _, upper := range b {
+		if size < upper {
+			return lower, upper
+		}
+		lower = upper
+	}
+	return lower, -1
+}
+
+// Label は size が属する区分の表示名を返す
+func (b SizeBuckets) Label(size int) string {
+	lower, upper := b.Bucket(size)
+	if upper < 0 {
+		return fmt.Sprintf("%v-", lower)
+	}
+	return fmt.Sprintf("%v-%v", lower, upper-1)
+}
+
+// SortLabels は Label が返した表示名をサイズの昇順に並べ替える
+func SortLabels(labels []string) {
+	lower := func(label string) int {
+		n, _ := strconv.Atoi(strings.SplitN(label, "-", 2)[0])
+		return n
+	}
+	sort.Slice(labels, func(i, j int) bool { return lower(labels[i]) < lower(labels[j]) })
+}
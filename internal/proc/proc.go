@@ -0,0 +1,72 @@
+// Package proc は同じバイナリのサブコマンドを子プロセスとして起動し、その出力をまとめる
+package proc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Output は子プロセスの出力を、どのプロセスの出力かが分かるようにまとめて書き出す
+type Output struct {
+	sync.Mutex
+	wg sync.WaitGroup
+}
+
+func (o *Output) copy(name string, r io.Reader, file *os.File, onLine func(string)) {
+	defer o.wg.Done()
+	if file != nil {
+		defer file.Close()
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1<<16), 1<<20)
+	for scanner.Scan() {
+		o.Lock()
+		fmt.Fprintf(os.Stdout, "[%v] %s\n", name, scanner.Bytes())
+		if file != nil {
+			fmt.Fprintf(file, "%s\n", scanner.Bytes())
+		}
+		o.Unlock()
+		if onLine != nil {
+			onLine(scanner.Text())
+		}
+	}
+}
+
+// Wait は起動した全ての子プロセスの出力を書き出し終えるまで待つ
+func (o *Output) Wait() {
+	o.wg.Wait()
+}
+
+// Start は同じバイナリのサブコマンド name を起動する
+// 出力の各行は name を付けて標準出力へ、logPath が空でない場合はそのまま logPath へ書き出し、onLine が nil でない場合は onLine に渡す
+func Start(exe, name string, args []string, out *Output, logPath string, onLine func(line string)) (*exec.Cmd, error) {
+	cmd := exec.Command(exe, append([]string{name}, args...)...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var file *os.File
+	if logPath != "" {
+		if file, err = os.Create(logPath); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Running: %v %v %v", filepath.Base(exe), name, strings.Join(args, " "))
+	if err := cmd.Start(); err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
+	}
+	out.wg.Add(1)
+	go out.copy(name, stdout, file, onLine)
+	return cmd, nil
+}
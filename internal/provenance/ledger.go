@@ -0,0 +1,192 @@
+package provenance
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Entry は台帳の 1 行
+// Hash は Hash を空にした Entry の JSON の SHA-256 で、次の Entry の Prev になる
+type Entry struct {
+	Seq  int       `json:"seq"`
+	Time time.Time `json:"time"`
+	Host string    `json:"host"`
+	// Files は記録したファイル（パスは台帳のディレクトリからの相対パス）
+	Files []File `json:"files"`
+	Prev  string `json:"prev"`
+	Hash  string `json:"hash"`
+}
+
+func (e Entry) digest() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Problem は Verify で見つかった不整合
+type Problem struct {
+	Seq  int
+	Path string
+	Msg  string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%v (Seq: %v)", p.Msg, p.Seq)
+	}
+	return fmt.Sprintf("%v: %v (Seq: %v)", p.Msg, p.Path, p.Seq)
+}
+
+// Seal は files のハッシュを台帳 ledger の末尾に追記する
+// 台帳が無い場合は作成する。pub と sub のスクリプトが同時に追記しても壊れないよう、追記中はロックファイルを作る
+func Seal(ledger string, files []string) (Entry, error) {
+	unlock, err := lock(ledger)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+
+	entries, err := ReadLedger(ledger)
+	if err != nil && !os.IsNotExist(err) {
+		return Entry{}, err
+	}
+	e := Entry{Seq: len(entries) + 1, Time: time.Now(), Files: []File{}}
+	e.Host, _ = os.Hostname()
+	if len(entries) > 0 {
+		e.Prev = entries[len(entries)-1].Hash
+	}
+	for _, f := range files {
+		h, err := HashFile(f)
+		if err != nil {
+			return Entry{}, err
+		}
+		if h.Path, err = relPath(filepath.Dir(ledger), f); err != nil {
+			return Entry{}, err
+		}
+		e.Files = append(e.Files, h)
+	}
+	if e.Hash, err = e.digest(); err != nil {
+		return Entry{}, err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, err
+	}
+	f, err := os.OpenFile(ledger, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return Entry{}, err
+	}
+	return e, f.Close()
+}
+
+// ReadLedger は台帳 ledger の全ての行を読み込む
+func ReadLedger(ledger string) ([]Entry, error) {
+	f, err := os.Open(ledger)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries := []Entry{}
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 1<<16), 1<<24)
+	for n := 1; s.Scan(); n++ {
+		e := Entry{}
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return entries, ProvenanceError{fmt.Sprintf("%v:%v: %v", ledger, n, err)}
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
+
+// Verify は台帳 ledger のハッシュチェーンと、記録されたファイルが改ざん・削除されていないかを検査する
+// 記録されたファイルがマニフェストの場合は、マニフェストに記録されたファイルも検査する
+// 台帳の末尾の行を削除されても検出できないため、Verify の返す末尾の Entry の Hash を別に控えておくとよい
+func Verify(ledger string) ([]Problem, Entry, error) {
+	entries, err := ReadLedger(ledger)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+	problems := []Problem{}
+	prev := ""
+	for i, e := range entries {
+		if e.Seq != i+1 {
+			problems = append(problems, Problem{Seq: e.Seq, Msg: fmt.Sprintf("Sequence number mismatch (expected: %v)", i+1)})
+		}
+		if e.Prev != prev {
+			problems = append(problems, Problem{Seq: e.Seq, Msg: "Hash chain is broken"})
+		}
+		if h, err := e.digest(); err != nil {
+			return nil, Entry{}, err
+		} else if h != e.Hash {
+			problems = append(problems, Problem{Seq: e.Seq, Msg: "Entry has been modified"})
+		}
+		prev = e.Hash
+		for _, f := range e.Files {
+			problems = append(problems, verifyFile(e.Seq, filepath.Dir(ledger), f)...)
+		}
+	}
+	last := Entry{}
+	if len(entries) > 0 {
+		last = entries[len(entries)-1]
+	}
+	return problems, last, nil
+}
+
+func verifyFile(seq int, dir string, f File) []Problem {
+	path := filepath.Join(dir, f.Path)
+	h, err := HashFile(path)
+	if os.IsNotExist(err) {
+		return []Problem{{Seq: seq, Path: path, Msg: "Missing file"}}
+	}
+	if err != nil {
+		return []Problem{{Seq: seq, Path: path, Msg: err.Error()}}
+	}
+	if h.SHA256 != f.SHA256 || h.Size != f.Size {
+		return []Problem{{Seq: seq, Path: path, Msg: "File has been modified"}}
+	}
+	if !strings.HasSuffix(path, ".manifest.json") {
+		return nil
+	}
+	m, err := ReadManifest(path)
+	if err != nil {
+		return []Problem{{Seq: seq, Path: path, Msg: err.Error()}}
+	}
+	problems := []Problem{}
+	for _, mf := range m.Files {
+		problems = append(problems, verifyFile(seq, filepath.Dir(path), mf)...)
+	}
+	return problems
+}
+
+// lock は path のロックファイルを作成し、ロックを解除する関数を返す
+func lock(path string) (func(), error) {
+	name := path + ".lock"
+	for i := 0; i < 100; i++ {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return nil, ProvenanceError{fmt.Sprintf("Could not lock %v (remove it if no other process is writing the ledger)", name)}
+}
@@ -0,0 +1,227 @@
+// Package provenance は計測結果の来歴（マニフェスト）と、結果ファイルの改ざんを検出するための台帳を扱う
+package provenance
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revision はビルドしたソースのリビジョン
+// ビルド時に -ldflags "-X location-based-mqtt-evaluation-tool/internal/provenance.Revision=..." で埋め込む
+var Revision = ""
+
+// Manifest は 1 回の実行の来歴
+type Manifest struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Config  map[string]string `json:"config"`
+	WorkDir string            `json:"work_dir"`
+	Start   time.Time         `json:"start"`
+	End     time.Time         `json:"end"`
+	Build   Build             `json:"build"`
+	Host    Host              `json:"host"`
+	// Files は実行結果のファイル（パスはマニフェストのディレクトリからの相対パス）
+	Files []File `json:"files"`
+}
+
+// Build は実行したバイナリのビルド情報
+type Build struct {
+	GoVersion  string   `json:"go_version"`
+	Path       string   `json:"path"`
+	Version    string   `json:"version"`
+	Revision   string   `json:"revision"`
+	Deps       []string `json:"deps"`
+	Executable File     `json:"executable"`
+}
+
+// Host は実行したマシンの概要
+type Host struct {
+	Hostname string `json:"hostname"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Kernel   string `json:"kernel"`
+	Distro   string `json:"distro"`
+	CPUModel string `json:"cpu_model"`
+	NumCPU   int    `json:"num_cpu"`
+	MemBytes int64  `json:"mem_bytes"`
+}
+
+// File はファイルのハッシュ
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// New は command の実行開始時点の来歴を生成する
+// fs の全てのフラグの値（既定値を含む）を設定として記録する
+func New(command string, fs *flag.FlagSet) *Manifest {
+	m := &Manifest{Command: command, Args: os.Args, Config: map[string]string{}, Start: time.Now()}
+	fs.VisitAll(func(f *flag.Flag) {
+		m.Config[f.Name] = f.Value.String()
+	})
+	m.WorkDir, _ = os.Getwd()
+	m.Build = readBuild()
+	m.Host = readHost()
+	return m
+}
+
+// Write は実行終了時刻と files のハッシュを記録して、マニフェストを path に書き出す
+// files のうち空文字列は無視する
+func (m *Manifest) Write(path string, files ...string) error {
+	m.End = time.Now()
+	m.Files = []File{}
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		h, err := HashFile(f)
+		if err != nil {
+			return err
+		}
+		if h.Path, err = relPath(filepath.Dir(path), f); err != nil {
+			return err
+		}
+		m.Files = append(m.Files, h)
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// ReadManifest は path のマニフェストを読み込む
+func ReadManifest(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, ProvenanceError{fmt.Sprintf("%v is not a manifest: %v", path, err)}
+	}
+	return m, nil
+}
+
+// HashFile は path のファイルの SHA-256 を求める
+func HashFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Path: path, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// relPath は base からの target の相対パスを返す
+func relPath(base, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absBase, absTarget)
+}
+
+func readBuild() Build {
+	b := Build{GoVersion: runtime.Version(), Revision: Revision}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		b.Path = info.Main.Path
+		b.Version = info.Main.Version
+		for _, d := range info.Deps {
+			if d.Replace != nil {
+				d = d.Replace
+			}
+			b.Deps = append(b.Deps, d.Path+"@"+d.Version)
+		}
+	}
+	if exe, err := os.Executable(); err == nil {
+		b.Executable, _ = HashFile(exe)
+	}
+	return b
+}
+
+func readHost() Host {
+	h := Host{OS: runtime.GOOS, Arch: runtime.GOARCH, NumCPU: runtime.NumCPU()}
+	h.Hostname, _ = os.Hostname()
+	if b, err := ioutil.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		h.Kernel = strings.TrimSpace(string(b))
+	} else {
+		h.Kernel = output("uname", "-r")
+	}
+	h.Distro = field("/etc/os-release", "PRETTY_NAME", "=")
+	if h.Distro == "" {
+		h.Distro = field("/etc/lsb-release", "DISTRIB_DESCRIPTION", "=")
+	}
+	if h.Distro == "" && runtime.GOOS == "darwin" {
+		h.Distro = "macOS " + output("sw_vers", "-productVersion")
+	}
+	h.CPUModel = field("/proc/cpuinfo", "model name", ":")
+	if h.CPUModel == "" {
+		h.CPUModel = output("sysctl", "-n", "machdep.cpu.brand_string")
+	}
+	if kb, err := strconv.ParseInt(strings.TrimSuffix(field("/proc/meminfo", "MemTotal", ":"), " kB"), 10, 64); err == nil {
+		h.MemBytes = kb * 1024
+	} else if b, err := strconv.ParseInt(output("sysctl", "-n", "hw.memsize"), 10, 64); err == nil {
+		h.MemBytes = b
+	}
+	return h
+}
+
+// field は "<key><sep><value>" の形の行が並ぶファイルから、最初に見つかった key の値を返す
+func field(path, key, sep string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), sep, 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == key {
+			return strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+	}
+	return ""
+}
+
+// output はコマンドの標準出力を返す（失敗した場合は空文字列）
+func output(name string, args ...string) string {
+	b, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// ProvenanceError は来歴や台帳を扱えないことを表す
+type ProvenanceError struct {
+	Msg string
+}
+
+func (e ProvenanceError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
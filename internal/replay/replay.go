@@ -0,0 +1,156 @@
+// Package replay は pub の Publish の予定（Gorutine ごとの時刻、位置、メッセージサイズ）を JSON Lines 形式で記録し、
+// 同じ通信を再現するために読み込む
+// 1 行目は Header、2 行目以降は Publish ごとの Entry とする
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// version は記録の形式を変更した際に更新する
+const version = 1
+
+// Header は記録全体に共通する設定
+type Header struct {
+	Version int    `json:"version"`
+	ID      string `json:"id"`
+	Codec   string `json:"codec"`
+	// Padding はメッセージの詰め物の元となる文字列
+	Padding    string `json:"padding"`
+	Goroutines int    `json:"goroutines"`
+	// WarmUpMs、MeasureMs は計測の開始からの計測の段階の区切り[ms]
+	WarmUpMs  int64 `json:"warmup_ms"`
+	MeasureMs int64 `json:"measure_ms"`
+	// Rate は記録した際に指定した Publish の頻度[pub/s]（Subscriber 側での判定に使う）
+	Rate float64 `json:"rate"`
+}
+
+// Entry は 1 回の Publish の予定
+// トピック名は位置と pub の -scheme などから決まるため記録しない
+type Entry struct {
+	// OffsetUs は計測の開始からの時間[us]
+	OffsetUs  int64   `json:"offset_us"`
+	Goroutine int     `json:"goroutine"`
+	Seq       int64   `json:"seq"`
+	Lat       float64 `json:"lat"`
+	Lng       float64 `json:"lng"`
+	Size      int     `json:"size"`
+	Phase     int     `json:"phase,omitempty"`
+	// Burst はバースト送信したことを表し、BurstOffsetUs は計測の開始からバーストの予定時刻までの時間[us]
+	Burst         bool  `json:"burst,omitempty"`
+	BurstOffsetUs int64 `json:"burst_offset_us,omitempty"`
+}
+
+// Writer は Publish の予定を記録する
+// 複数の goroutine から同時に呼び出してよい
+type Writer struct {
+	sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// Create は path に記録を作成し、h を書き込む
+func Create(path string, h Header) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	enc := json.NewEncoder(w)
+	h.Version = version
+	if err := enc.Encode(h); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Writer{f: f, w: w, enc: enc}, nil
+}
+
+// Write は e を書き込む（nil の Writer に対しては何もしない）
+func (w *Writer) Write(e Entry) error {
+	if w == nil {
+		return nil
+	}
+	w.Lock()
+	defer w.Unlock()
+	return w.enc.Encode(e)
+}
+
+// Close はバッファを書き出してファイルを閉じる（nil の Writer に対しては何もしない）
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.Lock()
+	defer w.Unlock()
+	if err := w.w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// Schedule は読み込んだ記録
+type Schedule struct {
+	Header
+	// Entries は Gorutine ごとの Publish の予定（時刻順）
+	Entries [][]Entry
+}
+
+// N は Publish の予定の総数を返す
+func (s *Schedule) N() int {
+	n := 0
+	for _, es := range s.Entries {
+		n += len(es)
+	}
+	return n
+}
+
+// Read は path の記録を読み込む
+func Read(path string) (*Schedule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReaderSize(f, 1<<20))
+	s := &Schedule{}
+	if err := dec.Decode(&s.Header); err != nil {
+		return nil, ReplayError{fmt.Sprintf("%v: invalid header: %v", path, err)}
+	}
+	if s.Version != version {
+		return nil, ReplayError{fmt.Sprintf("%v: unsupported version %v (expected: %v)", path, s.Version, version)}
+	}
+	if s.Goroutines <= 0 {
+		return nil, ReplayError{fmt.Sprintf("%v: invalid number of goroutines: %v", path, s.Goroutines)}
+	}
+	s.Entries = make([][]Entry, s.Goroutines)
+	for dec.More() {
+		e := Entry{}
+		if err := dec.Decode(&e); err != nil {
+			return nil, ReplayError{fmt.Sprintf("%v: %v", path, err)}
+		}
+		if e.Goroutine < 0 || e.Goroutine >= s.Goroutines {
+			return nil, ReplayError{fmt.Sprintf("%v: goroutine %v is out of range (goroutines: %v)", path, e.Goroutine, s.Goroutines)}
+		}
+		s.Entries[e.Goroutine] = append(s.Entries[e.Goroutine], e)
+	}
+	for _, es := range s.Entries {
+		sort.SliceStable(es, func(i, j int) bool { return es[i].OffsetUs < es[j].OffsetUs })
+	}
+	return s, nil
+}
+
+// ReplayError は記録を読み込めないことを表す
+type ReplayError struct {
+	Msg string
+}
+
+func (e ReplayError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
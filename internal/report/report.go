@@ -0,0 +1,205 @@
+// Package report は計測結果をグラフと表にまとめた、外部のファイルに依存しない HTML を生成する
+package report
+
+import (
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"location-based-mqtt-evaluation-tool/internal/result"
+	"location-based-mqtt-evaluation-tool/internal/stats"
+)
+
+var page = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 24px; color: #222; }
+table { border-collapse: collapse; margin: 8px 0 16px; font-size: 13px; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+td.num { text-align: right; }
+section { border-top: 2px solid #333; margin-top: 24px; }
+details { margin: 8px 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated at {{.Generated}}</p>
+{{range .Runs}}
+<section>
+<h2>{{.Path}}</h2>
+<details><summary>Configuration</summary>
+<table>{{range .Options}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>{{end}}</table>
+</details>
+{{range .Charts}}<div>{{.}}</div>{{end}}
+{{if .InterArrivals}}
+<table>
+<tr><th>ID</th><th>Publish intervals</th><th>Mean [us]</th><th>p50 [us]</th><th>p90 [us]</th><th>p99 [us]</th><th>CV</th></tr>
+{{range .InterArrivals}}<tr><td>{{.ID}}</td><td class="num">{{.N}}</td><td class="num">{{.Mean}}</td><td class="num">{{.P50}}</td><td class="num">{{.P90}}</td><td class="num">{{.P99}}</td><td class="num">{{.CV}}</td></tr>
+{{end}}</table>
+<p>Realized intervals between consecutive publishes of each goroutine (CV is 0 for fixed and close to 1 for Poisson arrivals).</p>
+{{end}}
+{{if .Bottlenecks}}<p><strong>The load generator itself may have been the bottleneck in {{.Bottlenecks}} interval(s).</strong></p>{{end}}
+{{if .IDs}}
+<table>
+<tr><th>ID</th><th>Received [msg]</th><th>Mean [ms]</th><th>Min [ms]</th><th>Max [ms]</th>{{range $.Percentiles}}<th>p{{.}} [ms]</th>{{end}}<th>Published [msg]</th></tr>
+{{range .IDs}}<tr><td>{{.ID}}</td><td class="num">{{.N}}</td><td class="num">{{.Mean}}</td><td class="num">{{.Min}}</td><td class="num">{{.Max}}</td>{{range .Percentiles}}<td class="num">{{.}}</td>{{end}}<td class="num">{{.Published}}</td></tr>
+{{end}}</table>
+<p>Percentiles are computed from the per-second average latencies.</p>
+{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+type option struct {
+	Name  string
+	Value string
+}
+
+type idRow struct {
+	ID          string
+	N           int64
+	Mean        string
+	Min         int64
+	Max         int64
+	Percentiles []string
+	Published   string
+}
+
+type arrivalRow struct {
+	ID   string
+	N    int64
+	Mean string
+	P50  int64
+	P90  int64
+	P99  int64
+	CV   string
+}
+
+type runView struct {
+	Path    string
+	Options []option
+	Charts  []template.HTML
+	IDs     []idRow
+	// Bottlenecks は負荷生成プロセス自身がボトルネックになっていた可能性がある区間の数
+	Bottlenecks   int
+	InterArrivals []arrivalRow
+}
+
+// percentiles は表に載せる遅延のパーセンタイル
+var percentiles = []float64{50, 90, 99}
+
+// Write は runs の計測結果をまとめた HTML を w に書き出す
+func Write(w io.Writer, title string, runs []*result.Run) error {
+	published := map[string]int64{}
+	for _, r := range runs {
+		for id, n := range r.Published {
+			published[id] += n
+		}
+	}
+	views := []runView{}
+	for _, r := range runs {
+		views = append(views, view(r, published))
+	}
+	return page.Execute(w, map[string]interface{}{
+		"Title":       title,
+		"Generated":   time.Now().Format(time.RFC3339),
+		"Runs":        views,
+		"Percentiles": percentiles,
+	})
+}
+
+func view(r *result.Run, published map[string]int64) runView {
+	v := runView{Path: r.Path, Bottlenecks: r.Bottlenecks}
+	names := make([]string, 0, len(r.Options))
+	for k := range r.Options {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v.Options = append(v.Options, option{k, r.Options[k]})
+	}
+
+	if len(r.RateSeries) > 0 {
+		v.Charts = append(v.Charts, svg(Chart{Title: "Throughput", XLabel: "Elapsed time [s]", YLabel: "Publish rate [pub/s]", Series: []Series{timeSeries("publish rate", r.RateSeries)}}))
+	}
+	if len(r.CPUSeries) > 0 {
+		v.Charts = append(v.Charts,
+			svg(Chart{Title: "Load generator CPU", XLabel: "Elapsed time [s]", YLabel: "CPU usage [%]", Series: []Series{timeSeries("cpu", r.CPUSeries)}}),
+			svg(Chart{Title: "Load generator memory", XLabel: "Elapsed time [s]", YLabel: "RSS [KB]", Series: []Series{timeSeries("rss", r.RSSSeries)}}))
+	}
+	arrivalIDs := make([]string, 0, len(r.InterArrivals))
+	for id := range r.InterArrivals {
+		arrivalIDs = append(arrivalIDs, id)
+	}
+	sort.Strings(arrivalIDs)
+	for _, id := range arrivalIDs {
+		a := r.InterArrivals[id]
+		v.InterArrivals = append(v.InterArrivals, arrivalRow{ID: id, N: a.N, Mean: format(a.Mean), P50: a.P50, P90: a.P90, P99: a.P99, CV: format(a.CV)})
+	}
+	ids := r.IDs()
+	if len(ids) == 0 {
+		return v
+	}
+	latency := Chart{Title: "Latency over time", XLabel: "Elapsed time [s]", YLabel: "Average latency [ms]"}
+	cdf := Chart{Title: "Latency CDF", XLabel: "Average latency per second [ms]", YLabel: "Cumulative fraction"}
+	for _, id := range ids {
+		samples := r.LatencySeries[id]
+		latency.Series = append(latency.Series, timeSeries(id, samples))
+		xs := make([]float64, len(samples))
+		for i, s := range samples {
+			xs[i] = s.V
+		}
+		cdf.Series = append(cdf.Series, cdfSeries(id, xs))
+
+		t := r.Totals[id]
+		row := idRow{ID: id, N: t.N, Mean: format(t.Mean), Min: t.Min, Max: t.Max, Published: "-"}
+		for _, q := range percentiles {
+			row.Percentiles = append(row.Percentiles, format(stats.Percentile(xs, q/100)))
+		}
+		if n, ok := published[id]; ok {
+			row.Published = format(float64(n))
+		}
+		v.IDs = append(v.IDs, row)
+	}
+	v.Charts = append(v.Charts, svg(latency), svg(cdf))
+	return v
+}
+
+func svg(c Chart) template.HTML {
+	return template.HTML(c.SVG())
+}
+
+func timeSeries(name string, samples []result.Sample) Series {
+	s := Series{Name: name}
+	for _, p := range samples {
+		s.X = append(s.X, p.T)
+		s.Y = append(s.Y, p.V)
+	}
+	return s
+}
+
+// cdfSeries は xs の経験累積分布関数を階段状の系列で返す
+func cdfSeries(name string, xs []float64) Series {
+	sorted := append([]float64{}, xs...)
+	sort.Float64s(sorted)
+	s := Series{Name: name}
+	for i, x := range sorted {
+		s.X = append(s.X, x, x)
+		s.Y = append(s.Y, float64(i)/float64(len(sorted)), float64(i+1)/float64(len(sorted)))
+	}
+	return s
+}
+
+func format(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return label(math.Round(v*100) / 100)
+}
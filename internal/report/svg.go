@@ -0,0 +1,109 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+)
+
+// グラフの大きさ[px]
+const (
+	chartWidth   = 760
+	chartHeight  = 320
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 40
+	marginBottom = 44
+)
+
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// Series はグラフの 1 系列
+type Series struct {
+	Name string
+	X    []float64
+	Y    []float64
+}
+
+// Chart は折れ線グラフ
+type Chart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Series []Series
+}
+
+// SVG は c を SVG で描画する
+func (c Chart) SVG() string {
+	xmin, xmax, ymin, ymax := math.Inf(1), math.Inf(-1), 0., math.Inf(-1)
+	for _, s := range c.Series {
+		for i := range s.X {
+			xmin, xmax = math.Min(xmin, s.X[i]), math.Max(xmax, s.X[i])
+			ymin, ymax = math.Min(ymin, s.Y[i]), math.Max(ymax, s.Y[i])
+		}
+	}
+	if math.IsInf(xmin, 0) {
+		xmin, xmax, ymax = 0, 1, 1
+	}
+	xticks, xmin, xmax := ticks(xmin, xmax)
+	yticks, ymin, ymax := ticks(ymin, ymax)
+	pw, ph := float64(chartWidth-marginLeft-marginRight), float64(chartHeight-marginTop-marginBottom)
+	px := func(x float64) float64 { return marginLeft + (x-xmin)/(xmax-xmin)*pw }
+	py := func(y float64) float64 { return marginTop + ph - (y-ymin)/(ymax-ymin)*ph }
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v" font-family="sans-serif" font-size="11">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(b, `<text x="%v" y="16" font-size="13" font-weight="bold">%v</text>`, marginLeft, html.EscapeString(c.Title))
+	for _, t := range xticks {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%v" x2="%.1f" y2="%.1f" stroke="#ddd"/>`, px(t), marginTop, px(t), marginTop+ph)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle">%v</text>`, px(t), marginTop+ph+14, label(t))
+	}
+	for _, t := range yticks {
+		fmt.Fprintf(b, `<line x1="%v" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`, marginLeft, py(t), marginLeft+pw, py(t))
+		fmt.Fprintf(b, `<text x="%v" y="%.1f" text-anchor="end">%v</text>`, marginLeft-4, py(t)+4, label(t))
+	}
+	fmt.Fprintf(b, `<rect x="%v" y="%v" width="%.1f" height="%.1f" fill="none" stroke="#333"/>`, marginLeft, marginTop, pw, ph)
+	fmt.Fprintf(b, `<text x="%.1f" y="%v" text-anchor="middle">%v</text>`, marginLeft+pw/2, chartHeight-6, html.EscapeString(c.XLabel))
+	fmt.Fprintf(b, `<text transform="translate(14 %.1f) rotate(-90)" text-anchor="middle">%v</text>`, marginTop+ph/2, html.EscapeString(c.YLabel))
+	for i, s := range c.Series {
+		color := palette[i%len(palette)]
+		b.WriteString(`<polyline fill="none" stroke-width="1.5" stroke="` + color + `" points="`)
+		for j := range s.X {
+			fmt.Fprintf(b, "%.1f,%.1f ", px(s.X[j]), py(s.Y[j]))
+		}
+		b.WriteString(`"/>`)
+		// 凡例
+		lx := float64(chartWidth-marginRight) - 160
+		ly := float64(marginTop) + 14 + float64(i)*14
+		fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="10" height="3" fill="%v"/>`, lx, ly-4, color)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f">%v</text>`, lx+14, ly, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// ticks は [min, max] を含むきりのよい目盛りと、目盛りに合わせて広げた範囲を返す
+func ticks(min, max float64) ([]float64, float64, float64) {
+	if max <= min {
+		max = min + 1
+	}
+	raw := (max - min) / 5
+	step := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if step*m >= raw {
+			step *= m
+			break
+		}
+	}
+	lo, hi := math.Floor(min/step)*step, math.Ceil(max/step)*step
+	ts := []float64{}
+	for t := lo; t <= hi+step/2; t += step {
+		ts = append(ts, t)
+	}
+	return ts, lo, hi
+}
+
+func label(v float64) string {
+	return fmt.Sprintf("%.6g", v)
+}
@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package resource
+
+import "time"
+
+// CPUTime はプロセスが使った CPU 時間を返す（getrusage の無い OS では常に 0）
+func CPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,18 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package resource
+
+import (
+	"syscall"
+	"time"
+)
+
+// CPUTime はプロセスが使った CPU 時間（ユーザ + システム）を返す（取得できない場合は 0）
+func CPUTime() time.Duration {
+	ru := syscall.Rusage{}
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,146 @@
+// Package resource は計測中の負荷生成プロセス自身の資源使用量（CPU、RSS、goroutine 数、GC、ファイルディスクリプタ）を定期的に取得する
+package resource
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample は 1 区間の資源使用量
+type Sample struct {
+	Time time.Time
+	// CPUPercent は区間内の CPU 使用率（GOMAXPROCS 個のコアを使い切った場合を 100 とする）
+	CPUPercent float64
+	// RSSBytes は区間の終わりの RSS（取得できない場合は 0）
+	RSSBytes   int64
+	Goroutines int
+	// GCs は区間内の GC の回数
+	GCs uint32
+	// GCPause, GCPauseMax は区間内の GC による停止時間の合計と最大
+	GCPause    time.Duration
+	GCPauseMax time.Duration
+	// FDs は区間の終わりに開いているファイルディスクリプタの数（取得できない場合は -1）
+	FDs int
+	// Interval は区間の長さ
+	Interval time.Duration
+}
+
+func (s Sample) String() string {
+	return fmt.Sprintf("cpu=%.1f [%%] rss=%v [KB] goroutines=%v gc=%v gcPause=%v [us] gcPauseMax=%v [us] fds=%v",
+		s.CPUPercent, s.RSSBytes/1024, s.Goroutines, s.GCs, s.GCPause.Nanoseconds()/1000, s.GCPauseMax.Nanoseconds()/1000, s.FDs)
+}
+
+// Bottleneck は負荷生成プロセス自身が計測のボトルネックになっていた可能性がある理由を返す（無い場合は空）
+// CPU 使用率が cpuLimit[%] 以上、または GC による停止時間が区間の gcLimit[%] 以上の場合にボトルネックとみなす
+func (s Sample) Bottleneck(cpuLimit, gcLimit float64) string {
+	reasons := []string{}
+	if s.CPUPercent >= cpuLimit {
+		reasons = append(reasons, fmt.Sprintf("cpu=%.1f%% >= %v%%", s.CPUPercent, cpuLimit))
+	}
+	if s.Interval > 0 {
+		if p := float64(s.GCPause) / float64(s.Interval) * 100; p >= gcLimit {
+			reasons = append(reasons, fmt.Sprintf("gc pause=%.1f%% >= %v%%", p, gcLimit))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// Sampler は前回の取得からの資源使用量を求める
+type Sampler struct {
+	last    time.Time
+	lastCPU time.Duration
+	lastGC  uint32
+}
+
+// NewSampler は現在を最初の区間の始まりとする Sampler を生成する
+func NewSampler() *Sampler {
+	s := &Sampler{last: time.Now(), lastCPU: CPUTime()}
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	s.lastGC = ms.NumGC
+	return s
+}
+
+// Sample は前回の取得から現在までの資源使用量を求める
+func (s *Sampler) Sample() Sample {
+	now, cpu := time.Now(), CPUTime()
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	r := Sample{Time: now, Interval: now.Sub(s.last), RSSBytes: rss(), Goroutines: runtime.NumGoroutine(), GCs: ms.NumGC - s.lastGC, FDs: fds()}
+	if r.Interval > 0 {
+		r.CPUPercent = float64(cpu-s.lastCPU) / float64(r.Interval) / float64(runtime.GOMAXPROCS(0)) * 100
+	}
+	// PauseNs は直近 256 回分のリングバッファで、i 回目の GC は (i + 255) % 256 番目
+	n := uint32(len(ms.PauseNs))
+	first := s.lastGC + 1
+	if ms.NumGC >= n && first <= ms.NumGC-n {
+		first = ms.NumGC - n + 1
+	}
+	for i := first; i <= ms.NumGC; i++ {
+		p := time.Duration(ms.PauseNs[(i+n-1)%n])
+		r.GCPause += p
+		if p > r.GCPauseMax {
+			r.GCPauseMax = p
+		}
+	}
+	s.last, s.lastCPU, s.lastGC = now, cpu, ms.NumGC
+	return r
+}
+
+// Start は interval ごとに資源使用量を取得して fn を呼び出す goroutine を起動し、それを止める関数を返す
+// 止める際には、最後の取得からの資源使用量でもう一度 fn を呼び出す
+func Start(interval time.Duration, fn func(Sample)) func() {
+	s := NewSampler()
+	stopCh, doneCh := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn(s.Sample())
+			case <-stopCh:
+				fn(s.Sample())
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// rss は /proc/self/statm から RSS を求める（Linux 以外では 0）
+func rss() int64 {
+	b, err := ioutil.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * int64(os.Getpagesize())
+}
+
+// fds は開いているファイルディスクリプタの数を返す
+func fds() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := ioutil.ReadDir(dir); err == nil {
+			// ReadDir 自身が開いたディレクトリの分を除く
+			return len(entries) - 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,246 @@
+// Package result は pub と sub の標準出力のログ（計測スクリプトが保存するログファイル）から計測結果を読み取る
+package result
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	optionRe     = regexp.MustCompile(`OPTION (.+?)\s+: (.*)$`)
+	rateRe       = regexp.MustCompile(`Publish rate: ([0-9]+) \[pub/s\]$`)
+	pubRe        = regexp.MustCompile(`Publish total: ([0-9]+) \[pub\] \(ID: (.+)\)$`)
+	averageRe    = regexp.MustCompile(`Average : ([0-9.eE+-]+) \[ms\] \[n=([0-9]+)\] \(ID: (.+)\)$`)
+	resourceRe   = regexp.MustCompile(`Resource : cpu=([0-9.]+) \[%\] rss=([0-9]+) \[KB\]`)
+	bottleneckRe = regexp.MustCompile(`\[Warning\] Load generator bottleneck : `)
+	arrivalRe    = regexp.MustCompile(`Inter-arrival total : ([0-9.eE+-]+) \[us\] \[n=([0-9]+)\] \[min=[0-9]+\] \[max=[0-9]+\] \[p50=([0-9]+)\] \[p90=([0-9]+)\] \[p99=([0-9]+)\] \[cv=([0-9.]+)\] \(ID: (.+)\)$`)
+	totalRe      = regexp.MustCompile(`Total : ([0-9.eE+-]+) \[ms\] \[n=([0-9]+)\] \[min=(-?[0-9]+)\] \[max=(-?[0-9]+)\] \(ID: (.+)\)$`)
+	quantileRe   = regexp.MustCompile(`Latency total : \S+ \[ms\] \[n=([0-9]+)\] \[min=-?[0-9]+\] \[max=-?[0-9]+\] \[p50=(-?[0-9]+)\] \[p90=(-?[0-9]+)\] \[p99=(-?[0-9]+)\] \[p99.9=(-?[0-9]+)\].*\(ID: (.+)\)$`)
+)
+
+// LatencyPercentiles は sub が ID ごとに表示する、メッセージごとの遅延のパーセンタイル
+var LatencyPercentiles = []float64{50, 90, 99, 99.9}
+
+// timeLayout はログの各行の先頭の時刻の形式（log.Ltime | log.Lmicroseconds）
+const timeLayout = "15:04:05.000000"
+
+// Sample は時系列の 1 点
+type Sample struct {
+	// T は実行開始（ログの最初の行）からの経過秒数
+	T float64
+	V float64
+	N int64
+}
+
+// Total は ID ごとの遅延の集計
+type Total struct {
+	Mean float64
+	N    int64
+	Min  int64
+	Max  int64
+}
+
+// InterArrival は実際に Publish した間隔[us]の分布の要約
+type InterArrival struct {
+	Mean float64
+	N    int64
+	P50  int64
+	P90  int64
+	P99  int64
+	// CV は変動係数（標準偏差 / 平均）
+	CV float64
+}
+
+// Run は 1 回の実行の計測結果
+type Run struct {
+	Path string
+	// Options は "OPTION <名前> : <値>" の行から読み取ったオプション
+	Options map[string]string
+	// RateSeries は 1 秒ごとの Publish 数（0 の秒は除く）
+	RateSeries []Sample
+	// LatencySeries は ID ごとの 1 秒ごとの平均遅延[ms]
+	LatencySeries map[string][]Sample
+	// Published は ID ごとの Publish 数
+	Published map[string]int64
+	// Totals は ID ごとの遅延の集計（N は全クライアントの受信数の合計）
+	Totals map[string]Total
+	// CPUSeries, RSSSeries は負荷生成プロセス自身の CPU 使用率[%]と RSS[KB]
+	CPUSeries []Sample
+	RSSSeries []Sample
+	// Bottlenecks は負荷生成プロセス自身がボトルネックになっていた可能性がある区間の数
+	Bottlenecks int
+	// InterArrivals は ID ごとの実際に Publish した間隔の分布
+	InterArrivals map[string]InterArrival
+	// Quantiles は ID ごとのメッセージごとの遅延[ms]のパーセンタイル（LatencyPercentiles の順、受信の無い ID は含めない）
+	Quantiles map[string][]int64
+}
+
+// Rates は 1 秒ごとの Publish 数を返す
+func (r *Run) Rates() []float64 {
+	xs := make([]float64, len(r.RateSeries))
+	for i, s := range r.RateSeries {
+		xs[i] = s.V
+	}
+	return xs
+}
+
+// Percentiles は ID ごとのメッセージごとの遅延[ms]の q パーセンタイルを ID 順に返す
+// q が LatencyPercentiles に含まれない場合は nil を返す
+func (r *Run) Percentiles(q float64) []float64 {
+	i := 0
+	for i < len(LatencyPercentiles) && LatencyPercentiles[i] != q {
+		i++
+	}
+	if i == len(LatencyPercentiles) {
+		return nil
+	}
+	ids := make([]string, 0, len(r.Quantiles))
+	for id := range r.Quantiles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	xs := []float64{}
+	for _, id := range ids {
+		xs = append(xs, float64(r.Quantiles[id][i]))
+	}
+	return xs
+}
+
+// IDs は遅延を計測した ID を昇順で返す
+func (r *Run) IDs() []string {
+	ids := make([]string, 0, len(r.LatencySeries))
+	for id := range r.LatencySeries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// IsSubscriber は r が sub の実行結果かを返す
+func (r *Run) IsSubscriber() bool {
+	_, ok := r.Options["Wait time"]
+	return ok
+}
+
+// Scenario は ignore に含まれない全てのオプションを "<名前>=<値>" の形で並べた文字列を返す
+// 同じ条件の実行を揃えるのに使う
+func (r *Run) Scenario(ignore map[string]bool) string {
+	keys := []string{}
+	for k := range r.Options {
+		if !ignore[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	params := make([]string, len(keys))
+	for i, k := range keys {
+		params[i] = k + "=" + r.Options[k]
+	}
+	return strings.Join(params, " ")
+}
+
+// Read は path のログファイルを読み込む
+func Read(path string) (*Run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &Run{Path: path, Options: map[string]string{}, LatencySeries: map[string][]Sample{}, Published: map[string]int64{}, Totals: map[string]Total{}, InterArrivals: map[string]InterArrival{}, Quantiles: map[string][]int64{}}
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 1<<16), 1<<24)
+	var start, prev time.Time
+	elapsed := 0.
+	for s.Scan() {
+		line := s.Text()
+		if t, err := time.Parse(timeLayout, strings.SplitN(line, " ", 2)[0]); err == nil {
+			if start.IsZero() {
+				start = t
+			} else if t.Before(prev.Add(-12 * time.Hour)) {
+				// 日付をまたいだ
+				start = start.Add(-24 * time.Hour)
+			}
+			prev = t
+			elapsed = t.Sub(start).Seconds()
+		}
+		if m := optionRe.FindStringSubmatch(line); m != nil {
+			r.Options[m[1]] = m[2]
+		} else if m := rateRe.FindStringSubmatch(line); m != nil {
+			if v, _ := strconv.ParseFloat(m[1], 64); v != 0 {
+				r.RateSeries = append(r.RateSeries, Sample{T: elapsed, V: v, N: int64(v)})
+			}
+		} else if m := pubRe.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.ParseInt(m[1], 10, 64)
+			r.Published[m[2]] += n
+		} else if m := averageRe.FindStringSubmatch(line); m != nil {
+			if n, _ := strconv.ParseInt(m[2], 10, 64); n > 0 {
+				v, _ := strconv.ParseFloat(m[1], 64)
+				r.LatencySeries[m[3]] = append(r.LatencySeries[m[3]], Sample{T: elapsed, V: v, N: n})
+			}
+		} else if m := resourceRe.FindStringSubmatch(line); m != nil {
+			cpu, _ := strconv.ParseFloat(m[1], 64)
+			kb, _ := strconv.ParseFloat(m[2], 64)
+			r.CPUSeries = append(r.CPUSeries, Sample{T: elapsed, V: cpu})
+			r.RSSSeries = append(r.RSSSeries, Sample{T: elapsed, V: kb})
+		} else if bottleneckRe.MatchString(line) {
+			r.Bottlenecks++
+		} else if m := arrivalRe.FindStringSubmatch(line); m != nil {
+			a := InterArrival{}
+			a.Mean, _ = strconv.ParseFloat(m[1], 64)
+			a.N, _ = strconv.ParseInt(m[2], 10, 64)
+			a.P50, _ = strconv.ParseInt(m[3], 10, 64)
+			a.P90, _ = strconv.ParseInt(m[4], 10, 64)
+			a.P99, _ = strconv.ParseInt(m[5], 10, 64)
+			a.CV, _ = strconv.ParseFloat(m[6], 64)
+			r.InterArrivals[m[7]] = a
+		} else if m := quantileRe.FindStringSubmatch(line); m != nil {
+			if n, _ := strconv.ParseInt(m[1], 10, 64); n > 0 {
+				qs := make([]int64, len(LatencyPercentiles))
+				for i := range qs {
+					qs[i], _ = strconv.ParseInt(m[i+2], 10, 64)
+				}
+				r.Quantiles[m[6]] = qs
+			}
+		} else if m := totalRe.FindStringSubmatch(line); m != nil {
+			t := Total{}
+			t.Mean, _ = strconv.ParseFloat(m[1], 64)
+			t.N, _ = strconv.ParseInt(m[2], 10, 64)
+			t.Min, _ = strconv.ParseInt(m[3], 10, 64)
+			t.Max, _ = strconv.ParseInt(m[4], 10, 64)
+			r.Totals[m[5]] = t
+		}
+	}
+	return r, s.Err()
+}
+
+// Loss は pubs の Publish 数に対する sub の実行結果 r の受信漏れの割合を返す
+// 全てのメッセージを受信する設定（受信半径が 0 以下）で、pubs に r の受信した ID がある場合のみ求められる
+func (r *Run) Loss(pubs []*Run) (float64, bool) {
+	// 値は "<半径> [KM]" の形
+	radius := strings.TrimSuffix(r.Options["Subscribe area radius"], " [KM]")
+	if v, err := strconv.ParseFloat(radius, 64); err != nil || v > 0 {
+		return 0, false
+	}
+	clients, err := strconv.ParseInt(r.Options["Client num"], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	expected, received := int64(0), int64(0)
+	for _, p := range pubs {
+		for id, n := range p.Published {
+			if t, ok := r.Totals[id]; ok {
+				expected += n * clients
+				received += t.N
+			}
+		}
+	}
+	if expected == 0 {
+		return 0, false
+	}
+	return 1 - float64(received)/float64(expected), true
+}
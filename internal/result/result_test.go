@@ -0,0 +1,86 @@
+package result
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const subLog = `10:00:00.000000 sub.go:98: OPTION Wait time                  : 1
+10:00:00.000000 sub.go:98: OPTION Client num                 : 2
+10:00:00.000000 sub.go:98: OPTION Subscribe area radius      : 0 [KM]
+10:00:01.000000 sub.go:400: Average : 12.5 [ms] [n=10] (ID: b)
+10:00:02.000000 sub.go:400: Average : 0 [ms] [n=0] (ID: b)
+10:00:05.000000 sub.go:489: Latency total : 12 [ms] [n=20] [min=1] [max=90] [p50=10] [p90=30] [p99=80] [p99.9=90] [negative=0] (ID: b)
+10:00:05.000000 sub.go:489: Latency total : 5 [ms] [n=8] [min=-2] [max=9] [p50=4] [p90=8] [p99=9] [p99.9=9] [negative=1] (ID: a)
+10:00:05.000000 sub.go:489: Latency total : NaN [ms] [n=0] [min=0] [max=0] [p50=0] [p90=0] [p99=0] [p99.9=0] [negative=0] (ID: c)
+10:00:05.000000 sub.go:500: Total : 12.5 [ms] [n=10] [min=1] [max=90] (ID: b)
+`
+
+const pubLog = `10:00:00.000000 pub.go:98: OPTION Process id                 : b
+10:00:01.000000 pub.go:700: Publish rate: 10 [pub/s]
+10:00:02.000000 pub.go:700: Publish rate: 0 [pub/s]
+10:00:05.000000 pub.go:800: Publish total: 10 [pub] (ID: b)
+`
+
+func TestRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	read := func(name, text string) *Run {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := Read(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	sub, pub := read("sub.log", subLog), read("pub.log", pubLog)
+
+	if !sub.IsSubscriber() || pub.IsSubscriber() {
+		t.Errorf("IsSubscriber = %v, %v, want true, false", sub.IsSubscriber(), pub.IsSubscriber())
+	}
+	if rates := pub.Rates(); len(rates) != 1 || rates[0] != 10 {
+		t.Errorf("Rates = %v, want [10]", rates)
+	}
+	if s := sub.LatencySeries["b"]; len(s) != 1 || s[0].V != 12.5 || s[0].T != 1 {
+		t.Errorf("LatencySeries = %+v, want one sample of 12.5 at 1 [s]", s)
+	}
+
+	// 受信の無い ID は含めず、パーセンタイルごとに ID 順の値を返す
+	tests := []struct {
+		q    float64
+		want []float64
+	}{
+		{50, []float64{4, 10}},
+		{90, []float64{8, 30}},
+		{99, []float64{9, 80}},
+		{99.9, []float64{9, 90}},
+		{95, nil},
+	}
+	for _, tt := range tests {
+		got := sub.Percentiles(tt.q)
+		if len(got) != len(tt.want) || tt.want == nil && got != nil {
+			t.Errorf("Percentiles(%v) = %v, want %v", tt.q, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Percentiles(%v) = %v, want %v", tt.q, got, tt.want)
+				break
+			}
+		}
+	}
+
+	// 受信数は全クライアントの合計なので、クライアント数で割って求める
+	if loss, ok := sub.Loss([]*Run{pub}); !ok || loss != 0.5 {
+		t.Errorf("Loss = %v, %v, want 0.5, true", loss, ok)
+	}
+}
@@ -0,0 +1,102 @@
+// Package runlog は計測後の解析に使うイベントログを JSON Lines 形式で読み書きする
+package runlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// イベントの種類
+const (
+	// TypePublish は Publisher がメッセージを Publish したことを表す
+	TypePublish = "pub"
+	// TypeArea は Subscriber のクライアントが受信範囲を設定（変更）したことを表す
+	// RadiusKm が 0 以下の場合は全てのメッセージを受信することを表す
+	TypeArea = "area"
+	// TypeEnd は Subscriber のクライアントが受信を終了したことを表す
+	TypeEnd = "end"
+	// TypeReceive は Subscriber のクライアントがメッセージを受信したことを表す
+	TypeReceive = "recv"
+)
+
+// Record はイベントログの 1 行
+type Record struct {
+	Type     string  `json:"type"`
+	Client   int     `json:"client,omitempty"`
+	ID       string  `json:"id,omitempty"`
+	Seq      int64   `json:"seq,omitempty"`
+	TimeMs   int64   `json:"time_ms"`
+	Lat      float64 `json:"lat,omitempty"`
+	Lng      float64 `json:"lng,omitempty"`
+	RadiusKm float64 `json:"radius_km,omitempty"`
+	Level    int     `json:"level,omitempty"`
+	// Phase は Publish した際の計測の段階（payload.Phase*）
+	Phase int `json:"phase,omitempty"`
+}
+
+// Writer はイベントログを書き込む
+// 複数の goroutine から同時に呼び出してよい
+type Writer struct {
+	sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// Create は path にイベントログを作成する
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	return &Writer{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Write は r を書き込む（nil の Writer に対しては何もしない）
+func (w *Writer) Write(r Record) error {
+	if w == nil {
+		return nil
+	}
+	w.Lock()
+	defer w.Unlock()
+	return w.enc.Encode(r)
+}
+
+// Close はバッファを書き出してファイルを閉じる（nil の Writer に対しては何もしない）
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.Lock()
+	defer w.Unlock()
+	if err := w.w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// Read は path のイベントログを読み込み、1 行ごとに fn を呼び出す
+func Read(path string, fn func(Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var r Record
+		if err := dec.Decode(&r); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
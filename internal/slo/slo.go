@@ -0,0 +1,215 @@
+// Package slo は計測結果に対する合否の判定条件（例: "p99 < 50ms"、"delivery > 99.9%"）を解釈して評価する
+package slo
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 指標の名前と単位
+// 評価する側が値を求められない指標の判定条件は評価しない（SKIP、既定では満たさなかったものとみなす）
+var metrics = map[string]string{
+	// 遅延[ms]
+	"mean":  "ms",
+	"p50":   "ms",
+	"p90":   "ms",
+	"p99":   "ms",
+	"p99.9": "ms",
+	"max":   "ms",
+	// 受信すべきメッセージのうち受信した割合[%]
+	"delivery": "%",
+	// 判定対象の受信メッセージのうち受信すべきだったものの割合[%]
+	"precision": "%",
+	// Publish の頻度の、指定した頻度からのずれ[%]
+	"rate_error": "%",
+	// 接続に失敗した回数
+	"connect_errors": "",
+	// 2 回目以降に受信したメッセージの数
+	"duplicates": "",
+	// 受信すべきでないのに受信したメッセージの数
+	"unexpected": "",
+}
+
+// Metrics は判定条件に使える指標の名前を返す
+func Metrics() []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var assertionRe = regexp.MustCompile(`^([a-z0-9_.]+)\s*(<=|>=|==|!=|<|>)\s*(-?[0-9]+(?:\.[0-9]+)?)\s*(ms|%)?$`)
+
+// Assertion は "<指標> <比較演算子> <値>[単位]" の形の判定条件
+type Assertion struct {
+	Metric string
+	Op     string
+	Value  float64
+}
+
+func (a Assertion) String() string {
+	return fmt.Sprintf("%v %v %v%v", a.Metric, a.Op, a.Value, metrics[a.Metric])
+}
+
+// Parse は s を判定条件として解釈する
+// 単位を省略した場合は指標の単位とみなし、指定した場合は指標の単位と一致しなければならない
+func Parse(s string) (Assertion, error) {
+	m := assertionRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Assertion{}, SLOError{fmt.Sprintf("Invalid assertion: %q (expected: <metric> <op> <value>[unit], e.g. \"p99 < 50ms\")", s)}
+	}
+	unit, ok := metrics[m[1]]
+	if !ok {
+		return Assertion{}, SLOError{fmt.Sprintf("Unknown metric: %v (available: %v)", m[1], strings.Join(Metrics(), ", "))}
+	}
+	if m[4] != "" && m[4] != unit {
+		return Assertion{}, SLOError{fmt.Sprintf("Unit mismatch: %q (the unit of %v is %q)", s, m[1], unit)}
+	}
+	v, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return Assertion{}, err
+	}
+	return Assertion{Metric: m[1], Op: m[2], Value: v}, nil
+}
+
+// Holds は値 v が判定条件を満たすかを返す
+func (a Assertion) Holds(v float64) bool {
+	switch a.Op {
+	case "<":
+		return v < a.Value
+	case "<=":
+		return v <= a.Value
+	case ">":
+		return v > a.Value
+	case ">=":
+		return v >= a.Value
+	case "==":
+		return v == a.Value
+	}
+	return v != a.Value
+}
+
+// Assertions は "-assert <判定条件>" を繰り返し指定したもの
+type Assertions []Assertion
+
+func (as *Assertions) String() string {
+	texts := make([]string, len(*as))
+	for i, a := range *as {
+		texts[i] = a.String()
+	}
+	return strings.Join(texts, ", ")
+}
+
+func (as *Assertions) Set(v string) error {
+	a, err := Parse(v)
+	if err != nil {
+		return err
+	}
+	*as = append(*as, a)
+	return nil
+}
+
+// ReadFile は path の判定条件を読み込んで as に加える
+// 1 行に 1 つの判定条件を書き、空行と "#" 以降は無視する
+func (as *Assertions) ReadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(strings.SplitN(s.Text(), "#", 2)[0])
+		if line == "" {
+			continue
+		}
+		if err := as.Set(line); err != nil {
+			return fmt.Errorf("%v:%v: %v", path, n, err)
+		}
+	}
+	return s.Err()
+}
+
+// Values は指標ごとの計測値
+// 値を求められなかった指標は含めないか NaN とする
+type Values map[string]float64
+
+// Verdict は 1 つの判定条件の評価結果
+type Verdict struct {
+	Assertion
+	Value float64
+	// Skipped は計測値が無く評価しなかったことを表す
+	Skipped bool
+	Pass    bool
+}
+
+func (v Verdict) result() string {
+	switch {
+	case v.Skipped:
+		return "SKIP"
+	case v.Pass:
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// Evaluate は as を values で評価する
+func Evaluate(as []Assertion, values Values) []Verdict {
+	verdicts := make([]Verdict, len(as))
+	for i, a := range as {
+		v, ok := values[a.Metric]
+		if !ok || math.IsNaN(v) {
+			verdicts[i] = Verdict{Assertion: a, Value: math.NaN(), Skipped: true}
+			continue
+		}
+		verdicts[i] = Verdict{Assertion: a, Value: v, Pass: a.Holds(v)}
+	}
+	return verdicts
+}
+
+// Print は評価結果を表の形で表示し、満たさない判定条件があったかを返す
+// 計測値が無く評価しなかった判定条件は、allowSkip が false の場合は満たさなかったものとみなす
+// （受信が無かった場合などに、遅延や受信率の判定条件を満たしたことにしないため）
+func Print(verdicts []Verdict, allowSkip bool) bool {
+	passed, failed, skipped := 0, 0, 0
+	for _, v := range verdicts {
+		value := "-"
+		if !v.Skipped {
+			value = fmt.Sprintf("%.6g%v", v.Value, metrics[v.Metric])
+		}
+		log.Printf("Assertion : %-24v value=%-12v %v", v.Assertion, value, v.result())
+		switch {
+		case v.Skipped:
+			skipped++
+		case v.Pass:
+			passed++
+		default:
+			failed++
+		}
+	}
+	fail := failed > 0 || !allowSkip && skipped > 0
+	result := "PASS"
+	if fail {
+		result = "FAIL"
+	}
+	log.Printf("Verdict : %v (passed=%v failed=%v skipped=%v)", result, passed, failed, skipped)
+	return fail
+}
+
+// SLOError は判定条件が不正であることを表す
+type SLOError struct {
+	Msg string
+}
+
+func (e SLOError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
@@ -0,0 +1,162 @@
+package slo
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		ok   bool
+		want Assertion
+	}{
+		{"p99 < 50ms", true, Assertion{"p99", "<", 50}},
+		{"p99<50", true, Assertion{"p99", "<", 50}},
+		{"  p99.9 <= 12.5ms ", true, Assertion{"p99.9", "<=", 12.5}},
+		{"delivery > 99.9%", true, Assertion{"delivery", ">", 99.9}},
+		{"rate_error >= -1.5%", true, Assertion{"rate_error", ">=", -1.5}},
+		{"duplicates == 0", true, Assertion{"duplicates", "==", 0}},
+		{"unexpected != 3", true, Assertion{"unexpected", "!=", 3}},
+		// 単位が指標の単位と異なる
+		{"p99 < 50%", false, Assertion{}},
+		{"duplicates == 0ms", false, Assertion{}},
+		{"p98 < 50ms", false, Assertion{}},
+		{"p99 =< 50ms", false, Assertion{}},
+		{"p99 < 50s", false, Assertion{}},
+		{"p99 < ms", false, Assertion{}},
+		{"P99 < 50ms", false, Assertion{}},
+		{"", false, Assertion{}},
+	}
+	for _, tt := range tests {
+		a, err := Parse(tt.s)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("Parse(%q): err = %v, want ok = %v", tt.s, err, tt.ok)
+			continue
+		}
+		if a != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.s, a, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, s := range []string{"p99 < 50ms", "delivery >= 99.9%", "duplicates == 0"} {
+		a, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.String(); got != s {
+			t.Errorf("String = %q, want %q", got, s)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	values := Values{"p99": 50, "delivery": 99.95, "duplicates": 0, "precision": math.NaN()}
+	tests := []struct {
+		s       string
+		pass    bool
+		skipped bool
+	}{
+		{"p99 < 50ms", false, false},
+		{"p99 <= 50ms", true, false},
+		{"p99 > 50ms", false, false},
+		{"p99 >= 50ms", true, false},
+		{"p99 == 50ms", true, false},
+		{"p99 != 50ms", false, false},
+		{"delivery > 99.9%", true, false},
+		{"duplicates == 0", true, false},
+		// 計測値が無い、または NaN の場合は評価しない
+		{"max < 100ms", false, true},
+		{"precision > 99%", false, true},
+	}
+	as := make([]Assertion, len(tests))
+	for i, tt := range tests {
+		a, err := Parse(tt.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		as[i] = a
+	}
+	verdicts := Evaluate(as, values)
+	if len(verdicts) != len(tests) {
+		t.Fatalf("Evaluate returned %v verdicts, want %v", len(verdicts), len(tests))
+	}
+	for i, tt := range tests {
+		v := verdicts[i]
+		if v.Pass != tt.pass || v.Skipped != tt.skipped {
+			t.Errorf("%q: Pass=%v Skipped=%v, want %v %v", tt.s, v.Pass, v.Skipped, tt.pass, tt.skipped)
+		}
+		if tt.skipped != math.IsNaN(v.Value) {
+			t.Errorf("%q: Value = %v", tt.s, v.Value)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	values := Values{"p99": 50}
+	pass, fail, skip := Assertion{"p99", "<=", 50}, Assertion{"p99", "<", 50}, Assertion{"delivery", ">", 99.9}
+	// 評価しなかった判定条件は、明示的に許可しない限り満たさなかったものとみなす
+	tests := []struct {
+		name      string
+		as        []Assertion
+		values    Values
+		allowSkip bool
+		fail      bool
+	}{
+		{"passed", []Assertion{pass}, values, false, false},
+		{"failed", []Assertion{pass, fail}, values, true, true},
+		{"skipped", []Assertion{skip}, values, false, true},
+		{"skipped allowed", []Assertion{skip}, values, true, false},
+		{"passed and skipped", []Assertion{pass, skip}, values, false, true},
+		// 受信が無く、遅延を求められなかった場合
+		{"no values", []Assertion{pass}, Values{}, false, true},
+		{"none", nil, values, false, false},
+	}
+	for _, tt := range tests {
+		if got := Print(Evaluate(tt.as, tt.values), tt.allowSkip); got != tt.fail {
+			t.Errorf("%v: Print(allowSkip=%v) = %v, want %v", tt.name, tt.allowSkip, got, tt.fail)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		text string
+		ok   bool
+		n    int
+	}{
+		{"p99 < 50ms\n\n# comment\ndelivery > 99% # trailing\n", true, 2},
+		{"", true, 0},
+		{"p99 < 50ms\nlatency < 1\n", false, 0},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "slo.txt")
+		if err := ioutil.WriteFile(path, []byte(tt.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var as Assertions
+		err := as.ReadFile(path)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%v: ReadFile: err = %v, want ok = %v", i, err, tt.ok)
+			continue
+		}
+		if tt.ok && len(as) != tt.n {
+			t.Errorf("%v: ReadFile = %v, want %v assertions", i, as.String(), tt.n)
+		}
+	}
+	var as Assertions
+	if err := as.ReadFile(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Errorf("ReadFile of a missing file succeeded, want error")
+	}
+}
@@ -0,0 +1,117 @@
+// Package stats は計測結果の比較に使う統計量と検定を求める
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Percentile は xs の q (0 <= q <= 1) 分位点を線形補間で求める（xs が空の場合は NaN）
+func Percentile(xs []float64, q float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	s := append([]float64{}, xs...)
+	sort.Float64s(s)
+	return percentileSorted(s, q)
+}
+
+func percentileSorted(s []float64, q float64) float64 {
+	pos := q * float64(len(s)-1)
+	i := int(pos)
+	if i >= len(s)-1 {
+		return s[len(s)-1]
+	}
+	return s[i] + (s[i+1]-s[i])*(pos-float64(i))
+}
+
+// Median は xs の中央値を求める（xs が空の場合は NaN）
+func Median(xs []float64) float64 {
+	return Percentile(xs, 0.5)
+}
+
+// Mean は xs の平均を求める（xs が空の場合は NaN）
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	sum := 0.
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// MannWhitneyU は a と b の分布に差が無いという帰無仮説に対する Mann–Whitney の U 検定を行い、
+// a の U 統計量と両側 p 値を返す
+// p 値は同順位を補正した正規近似で求める（どちらかが空の場合は NaN）
+func MannWhitneyU(a, b []float64) (float64, float64) {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return math.NaN(), math.NaN()
+	}
+	type value struct {
+		x     float64
+		group int
+	}
+	values := make([]value, 0, len(a)+len(b))
+	for _, x := range a {
+		values = append(values, value{x, 0})
+	}
+	for _, x := range b {
+		values = append(values, value{x, 1})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].x < values[j].x })
+
+	// 同じ値には平均順位を付ける
+	rankSum, ties := 0., 0.
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].x == values[i].x {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].group == 0 {
+				rankSum += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	u := rankSum - n1*(n1+1)/2
+	n := n1 + n2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return u, 1
+	}
+	// 連続修正
+	z := (math.Abs(u-n1*n2/2) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return u, math.Erfc(z / math.Sqrt2)
+}
+
+// BootstrapCI は stat(b) - stat(a) の信頼水準 level の信頼区間を、それぞれを n 回復元抽出して求める
+// どちらかが空の場合は NaN を返す
+func BootstrapCI(a, b []float64, stat func([]float64) float64, n int, level float64, rng *rand.Rand) (float64, float64) {
+	if len(a) == 0 || len(b) == 0 || n <= 0 {
+		return math.NaN(), math.NaN()
+	}
+	diffs := make([]float64, n)
+	ra, rb := make([]float64, len(a)), make([]float64, len(b))
+	for i := range diffs {
+		for j := range ra {
+			ra[j] = a[rng.Intn(len(a))]
+		}
+		for j := range rb {
+			rb[j] = b[rng.Intn(len(b))]
+		}
+		diffs[i] = stat(rb) - stat(ra)
+	}
+	sort.Float64s(diffs)
+	return percentileSorted(diffs, (1-level)/2), percentileSorted(diffs, (1+level)/2)
+}
@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		xs   []float64
+		q    float64
+		want float64
+	}{
+		{[]float64{4, 1, 3, 2}, 0, 1},
+		{[]float64{4, 1, 3, 2}, 0.5, 2.5},
+		{[]float64{4, 1, 3, 2}, 1, 4},
+		{[]float64{4, 1, 3, 2}, 0.25, 1.75},
+		{[]float64{7}, 0.99, 7},
+	}
+	for _, tt := range tests {
+		if got := Percentile(tt.xs, tt.q); !near(got, tt.want, 1e-12) {
+			t.Errorf("Percentile(%v, %v) = %v, want %v", tt.xs, tt.q, got, tt.want)
+		}
+	}
+	if got := Percentile(nil, 0.5); !math.IsNaN(got) {
+		t.Errorf("Percentile(nil) = %v, want NaN", got)
+	}
+	if got := Mean(nil); !math.IsNaN(got) {
+		t.Errorf("Mean(nil) = %v, want NaN", got)
+	}
+	if got := Median([]float64{3, 1, 2}); got != 2 {
+		t.Errorf("Median = %v, want 2", got)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	// 期待値は R の wilcox.test(a, b, exact = FALSE, correct = TRUE) と同じ
+	tests := []struct {
+		name  string
+		a, b  []float64
+		wantU float64
+		wantP float64
+	}{
+		{"separated", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0, 0.01219},
+		{"reversed", []float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 25, 0.01219},
+		{"ties", []float64{1, 1, 2}, []float64{2, 3, 3}, 0.5, 0.1101},
+		{"same", []float64{1, 2, 3}, []float64{1, 2, 3}, 4.5, 1},
+		{"all equal", []float64{5, 5}, []float64{5, 5, 5}, 3, 1},
+	}
+	for _, tt := range tests {
+		u, p := MannWhitneyU(tt.a, tt.b)
+		if u != tt.wantU || !near(p, tt.wantP, 5e-5) {
+			t.Errorf("%v: MannWhitneyU = (%v, %v), want (%v, %v)", tt.name, u, p, tt.wantU, tt.wantP)
+		}
+	}
+	if u, p := MannWhitneyU(nil, []float64{1}); !math.IsNaN(u) || !math.IsNaN(p) {
+		t.Errorf("MannWhitneyU(nil, ...) = (%v, %v), want NaN", u, p)
+	}
+}
+
+func TestBootstrapCI(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	normal := func(n int, mean float64) []float64 {
+		xs := make([]float64, n)
+		for i := range xs {
+			xs[i] = mean + rng.NormFloat64()
+		}
+		return xs
+	}
+	tests := []struct {
+		name   string
+		a, b   []float64
+		stat   func([]float64) float64
+		lo, hi float64
+	}{
+		// 値が一定の場合は差そのもの
+		{"constant", []float64{1, 1, 1}, []float64{3, 3, 3}, Mean, 2, 2},
+		{"constant median", []float64{5, 5}, []float64{4, 4, 4}, Median, -1, -1},
+	}
+	for _, tt := range tests {
+		lo, hi := BootstrapCI(tt.a, tt.b, tt.stat, 200, 0.95, rng)
+		if !near(lo, tt.lo, 1e-12) || !near(hi, tt.hi, 1e-12) {
+			t.Errorf("%v: BootstrapCI = [%v, %v], want [%v, %v]", tt.name, lo, hi, tt.lo, tt.hi)
+		}
+	}
+
+	// 平均の差が 1 の場合、区間は 1 を含み 0 を含まない
+	a, b := normal(500, 0), normal(500, 1)
+	lo, hi := BootstrapCI(a, b, Mean, 1000, 0.95, rng)
+	if !(lo < 1 && 1 < hi) || lo <= 0 {
+		t.Errorf("BootstrapCI of shifted normals = [%v, %v], want to contain 1 and exclude 0", lo, hi)
+	}
+	// 信頼水準を上げると区間は広がる
+	lo99, hi99 := BootstrapCI(a, b, Mean, 1000, 0.99, rand.New(rand.NewSource(2)))
+	if hi99-lo99 <= hi-lo {
+		t.Errorf("99%% interval [%v, %v] is not wider than 95%% interval [%v, %v]", lo99, hi99, lo, hi)
+	}
+
+	if lo, hi := BootstrapCI(nil, b, Mean, 100, 0.95, rng); !math.IsNaN(lo) || !math.IsNaN(hi) {
+		t.Errorf("BootstrapCI(nil, ...) = [%v, %v], want NaN", lo, hi)
+	}
+	if lo, hi := BootstrapCI(a, b, Mean, 0, 0.95, rng); !math.IsNaN(lo) || !math.IsNaN(hi) {
+		t.Errorf("BootstrapCI with n=0 = [%v, %v], want NaN", lo, hi)
+	}
+}
@@ -0,0 +1,397 @@
+// Package timeseries は計測値を一定間隔の区間ごとに集計して保持する
+package timeseries
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bucket は 1 区間分の集計値
+type Bucket struct {
+	Start time.Time
+	N     int64
+	Sum   int64
+	Min   int64
+	Max   int64
+}
+
+// Mean は区間内の平均値を返す（値が無い区間は 0）
+func (b Bucket) Mean() float64 {
+	if b.N == 0 {
+		return 0.
+	}
+	return float64(b.Sum) / float64(b.N)
+}
+
+func (b *Bucket) add(v int64) {
+	if b.N == 0 || v < b.Min {
+		b.Min = v
+	}
+	if b.N == 0 || v > b.Max {
+		b.Max = v
+	}
+	b.N++
+	b.Sum += v
+}
+
+func (b *Bucket) merge(o Bucket) {
+	if o.N == 0 {
+		return
+	}
+	if b.N == 0 || o.Min < b.Min {
+		b.Min = o.Min
+	}
+	if b.N == 0 || o.Max > b.Max {
+		b.Max = o.Max
+	}
+	b.N += o.N
+	b.Sum += o.Sum
+}
+
+// Point は ID 付きの区間集計値
+type Point struct {
+	ID string
+	Bucket
+}
+
+// series は 1 ID 分のリングバッファ
+type series struct {
+	buckets []Bucket
+	begin   int64 // 最初に値が記録された区間番号
+	head    int64 // 最新の区間番号
+	total   Bucket
+	evicted int64
+}
+
+// Recorder は (ID, 区間) ごとの集計値をリングバッファで保持する
+// 値の書き込みは Writer ごとに溜めておき、読み出し時にまとめてリングバッファへ反映する
+type Recorder struct {
+	sync.Mutex
+	interval time.Duration
+	capacity int
+	series   map[string]*series
+	writers  []*Writer
+	shared   *Writer
+	lastAdd  int64
+	closed   atomic.Value // map[string]bool
+	closeAll int32
+}
+
+// NewRecorder は区間幅 interval、ID ごとに capacity 区間分を保持する Recorder を生成する
+func NewRecorder(interval time.Duration, capacity int) *Recorder {
+	if capacity < 1 {
+		capacity = 1
+	}
+	r := &Recorder{interval: interval, capacity: capacity, series: map[string]*series{}}
+	r.closed.Store(map[string]bool{})
+	r.shared = r.NewWriter()
+	return r
+}
+
+// Interval は区間幅を返す
+func (r *Recorder) Interval() time.Duration {
+	return r.interval
+}
+
+func (r *Recorder) index(t time.Time) int64 {
+	return t.UnixNano() / int64(r.interval)
+}
+
+func (r *Recorder) start(idx int64) time.Time {
+	return time.Unix(0, idx*int64(r.interval))
+}
+
+func (r *Recorder) isClosed(id string) bool {
+	if atomic.LoadInt32(&r.closeAll) == 1 {
+		return true
+	}
+	return r.closed.Load().(map[string]bool)[id]
+}
+
+// pending は Writer が読み出し前に溜めている 1 ID 分の値
+type pending struct {
+	idx  int64
+	cur  Bucket
+	done []Bucket
+}
+
+// Writer は Recorder への書き込み口
+// goroutine ごとに NewWriter で生成して使うことで、書き込み時のロック競合を避ける
+type Writer struct {
+	sync.Mutex
+	r       *Recorder
+	pending map[string]*pending
+	lastAdd int64
+}
+
+// NewWriter は Recorder に Writer を追加する
+func (r *Recorder) NewWriter() *Writer {
+	r.Lock()
+	defer r.Unlock()
+	w := &Writer{r: r, pending: map[string]*pending{}}
+	r.writers = append(r.writers, w)
+	return w
+}
+
+// Add は時刻 at に観測した値 v を記録する
+// Close 済みの ID に対しては何もせず false を返す
+func (w *Writer) Add(id string, at time.Time, v int64) bool {
+	r := w.r
+	if r.isClosed(id) {
+		return false
+	}
+	idx := r.index(at)
+	w.Lock()
+	defer w.Unlock()
+	p, ok := w.pending[id]
+	if !ok {
+		p = &pending{idx: idx, cur: Bucket{Start: r.start(idx)}}
+		w.pending[id] = p
+	} else if p.idx != idx {
+		p.done = append(p.done, p.cur)
+		p.idx = idx
+		p.cur = Bucket{Start: r.start(idx)}
+	}
+	p.cur.add(v)
+	if ns := at.UnixNano(); ns > w.lastAdd {
+		w.lastAdd = ns
+	}
+	return true
+}
+
+func (w *Writer) take() (map[string]*pending, int64) {
+	w.Lock()
+	defer w.Unlock()
+	p := w.pending
+	w.pending = map[string]*pending{}
+	return p, w.lastAdd
+}
+
+// Add は Recorder 共有の Writer を使って値を記録する
+func (r *Recorder) Add(id string, at time.Time, v int64) bool {
+	return r.shared.Add(id, at, v)
+}
+
+// drain は全 Writer に溜まっている値をリングバッファへ反映する（r のロックを取得してから呼ぶ）
+func (r *Recorder) drain() {
+	byID := map[string][]Bucket{}
+	for _, w := range r.writers {
+		pendings, lastAdd := w.take()
+		if lastAdd > r.lastAdd {
+			r.lastAdd = lastAdd
+		}
+		for id, p := range pendings {
+			byID[id] = append(append(byID[id], p.done...), p.cur)
+		}
+	}
+	// 他の Writer に溜まっていた古い区間の値を、最初の区間より前の値として扱わないよう時刻順に反映する
+	for id, bs := range byID {
+		sort.SliceStable(bs, func(i, j int) bool { return bs[i].Start.Before(bs[j].Start) })
+		for _, b := range bs {
+			r.addBucket(id, b)
+		}
+	}
+}
+
+func (r *Recorder) addBucket(id string, b Bucket) {
+	idx := r.index(b.Start)
+	s, ok := r.series[id]
+	if !ok {
+		s = &series{buckets: make([]Bucket, r.capacity), begin: idx, head: idx}
+		s.buckets[idx%int64(r.capacity)] = Bucket{Start: b.Start}
+		r.series[id] = s
+	}
+	r.advance(s, idx)
+	s.total.merge(b)
+	if idx <= s.head-int64(r.capacity) || idx < s.begin {
+		// リングバッファから溢れた古い区間の値は合計値にのみ反映する
+		s.evicted += b.N
+		return
+	}
+	s.buckets[idx%int64(r.capacity)].merge(b)
+}
+
+// advance は head を idx まで進め、間の区間を 0 件の区間として埋める
+func (r *Recorder) advance(s *series, idx int64) {
+	for i := s.head + 1; i <= idx; i++ {
+		slot := i % int64(r.capacity)
+		if i-int64(r.capacity) >= s.begin {
+			s.evicted += s.buckets[slot].N
+		}
+		s.buckets[slot] = Bucket{Start: r.start(i)}
+	}
+	if idx > s.head {
+		s.head = idx
+	}
+}
+
+// Close は id の記録を終了する
+// 既に終了していた場合や未知の ID の場合は false を返す
+func (r *Recorder) Close(id string) bool {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	if _, ok := r.series[id]; !ok || r.isClosed(id) {
+		return false
+	}
+	// Writer はロックを取らずに参照するため、コピーしてから差し替える
+	old := r.closed.Load().(map[string]bool)
+	closed := make(map[string]bool, len(old)+1)
+	for k, v := range old {
+		closed[k] = v
+	}
+	closed[id] = true
+	r.closed.Store(closed)
+	return true
+}
+
+// CloseAll は全 ID の記録を終了する
+func (r *Recorder) CloseAll() {
+	atomic.StoreInt32(&r.closeAll, 1)
+}
+
+// IsClosed は id の記録が終了しているかを返す
+func (r *Recorder) IsClosed(id string) bool {
+	return r.isClosed(id)
+}
+
+// LastActivity は最後に値が記録された時刻を返す
+func (r *Recorder) LastActivity() time.Time {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	if r.lastAdd == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, r.lastAdd)
+}
+
+// IDs は記録済みの ID を昇順で返す
+func (r *Recorder) IDs() []string {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	return r.ids()
+}
+
+func (r *Recorder) ids() []string {
+	ids := make([]string, 0, len(r.series))
+	for id := range r.series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Window は id の [from, to) に含まれる区間を返す
+// 値が無かった区間も 0 件の区間として含まれる
+func (r *Recorder) Window(id string, from, to time.Time) []Bucket {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	s, ok := r.series[id]
+	if !ok {
+		return nil
+	}
+	return r.window(s, r.index(from), r.index(to))
+}
+
+func (r *Recorder) window(s *series, from, to int64) []Bucket {
+	if oldest := s.head - int64(r.capacity) + 1; from < oldest {
+		from = oldest
+	}
+	if from < s.begin {
+		from = s.begin
+	}
+	if to > s.head+1 {
+		to = s.head + 1
+	}
+	result := []Bucket{}
+	for i := from; i < to; i++ {
+		result = append(result, s.buckets[i%int64(r.capacity)])
+	}
+	return result
+}
+
+// Total は id の記録開始からの合計値を返す
+func (r *Recorder) Total(id string) Bucket {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	s, ok := r.series[id]
+	if !ok {
+		return Bucket{}
+	}
+	return s.total
+}
+
+// Evicted はリングバッファから溢れた値の数を返す（Total には含まれている）
+func (r *Recorder) Evicted(id string) int64 {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	s, ok := r.series[id]
+	if !ok {
+		return 0
+	}
+	return s.evicted
+}
+
+// Cursor は Collect で読み出した位置を ID ごとに保持する
+type Cursor struct {
+	next map[string]int64
+}
+
+// NewCursor は Cursor を生成する
+func NewCursor() *Cursor {
+	return &Cursor{next: map[string]int64{}}
+}
+
+// Collect は cursor が前回読み出した区間の続きから、until の時点で完了している区間までを返す
+// 値が無い区間も 0 件として返すため、読み出し側の呼び出し間隔によって区間が欠けることはない
+func (r *Recorder) Collect(c *Cursor, until time.Time) []Point {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	end := r.index(until)
+	for id, s := range r.series {
+		if !r.isClosed(id) {
+			r.advance(s, end-1)
+		}
+	}
+	return r.collect(c, end)
+}
+
+// Flush は cursor が未読の区間を、記録中の区間も含めて全て返す
+func (r *Recorder) Flush(c *Cursor) []Point {
+	r.Lock()
+	defer r.Unlock()
+	r.drain()
+	end := int64(0)
+	for _, s := range r.series {
+		if s.head+1 > end {
+			end = s.head + 1
+		}
+	}
+	return r.collect(c, end)
+}
+
+func (r *Recorder) collect(c *Cursor, end int64) []Point {
+	result := []Point{}
+	for _, id := range r.ids() {
+		s := r.series[id]
+		from, ok := c.next[id]
+		if !ok {
+			from = s.begin
+		}
+		for _, b := range r.window(s, from, end) {
+			result = append(result, Point{ID: id, Bucket: b})
+		}
+		if end > from {
+			c.next[id] = end
+		}
+	}
+	return result
+}
@@ -0,0 +1,281 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+)
+
+var t0 = time.Unix(1000, 0)
+
+func at(sec float64) time.Time {
+	return t0.Add(time.Duration(sec * float64(time.Second)))
+}
+
+// counts は区間ごとの件数と、区間の開始時刻が t0 から何秒後かを返す
+func counts(bs []Bucket) ([]int64, []int64) {
+	ns, starts := []int64{}, []int64{}
+	for _, b := range bs {
+		ns = append(ns, b.N)
+		starts = append(starts, int64(b.Start.Sub(t0)/time.Second))
+	}
+	return ns, starts
+}
+
+func pointCounts(ps []Point) ([]int64, []int64) {
+	bs := []Bucket{}
+	for _, p := range ps {
+		bs = append(bs, p.Bucket)
+	}
+	return counts(bs)
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBucket(t *testing.T) {
+	r := NewRecorder(time.Second, 10)
+	for _, v := range []int64{5, -3, 10} {
+		r.Add("a", at(0.5), v)
+	}
+	b := r.Total("a")
+	if b.N != 3 || b.Sum != 12 || b.Min != -3 || b.Max != 10 || b.Mean() != 4 {
+		t.Errorf("Total = %+v (mean %v), want N=3 Sum=12 Min=-3 Max=10 mean 4", b, b.Mean())
+	}
+	if m := (Bucket{}).Mean(); m != 0 {
+		t.Errorf("Mean of empty bucket = %v, want 0", m)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		adds     []float64
+		from, to float64
+		wantN    []int64
+		// wantStart は各区間の開始時刻（t0 からの秒数）
+		wantStart   []int64
+		wantTotal   int64
+		wantEvicted int64
+	}{
+		{"single", 10, []float64{0.1, 0.2}, 0, 1, []int64{2}, []int64{0}, 2, 0},
+		{"idle seconds are filled", 10, []float64{0.5, 3.5}, 0, 10, []int64{1, 0, 0, 1}, []int64{0, 1, 2, 3}, 2, 0},
+		{"window is clipped", 10, []float64{0, 1, 2, 3}, 1, 3, []int64{1, 1}, []int64{1, 2}, 4, 0},
+		{"before the first value", 10, []float64{5}, 0, 10, []int64{1}, []int64{5}, 1, 0},
+		{"wraparound", 3, []float64{0, 1, 2, 3, 4}, 0, 10, []int64{1, 1, 1}, []int64{2, 3, 4}, 5, 2},
+		{"wraparound over idle seconds", 3, []float64{0, 0, 1, 7}, 0, 10, []int64{0, 0, 1}, []int64{5, 6, 7}, 4, 3},
+		{"late value for an evicted interval", 3, []float64{0, 4, 0.5}, 0, 10, []int64{0, 0, 1}, []int64{2, 3, 4}, 3, 2},
+		{"out of order within capacity", 3, []float64{1, 2, 1.5}, 0, 10, []int64{2, 1}, []int64{1, 2}, 3, 0},
+		// 最初に値が記録された区間より前の値は合計値にのみ反映する
+		{"before the first interval", 3, []float64{2, 1, 2}, 0, 10, []int64{2}, []int64{2}, 3, 1},
+		{"capacity one", 1, []float64{0, 1, 1}, 0, 10, []int64{2}, []int64{1}, 3, 1},
+	}
+	for _, tt := range tests {
+		r := NewRecorder(time.Second, tt.capacity)
+		for _, sec := range tt.adds {
+			// 区間ごとに読み出しを挟み、リングバッファへ順に反映させる
+			r.Add("a", at(sec), 1)
+			r.Total("a")
+		}
+		n, start := counts(r.Window("a", at(tt.from), at(tt.to)))
+		if !equal(n, tt.wantN) || !equal(start, tt.wantStart) {
+			t.Errorf("%v: Window = %v (start %v), want %v (start %v)", tt.name, n, start, tt.wantN, tt.wantStart)
+		}
+		if got := r.Total("a").N; got != tt.wantTotal {
+			t.Errorf("%v: Total = %v, want %v", tt.name, got, tt.wantTotal)
+		}
+		if got := r.Evicted("a"); got != tt.wantEvicted {
+			t.Errorf("%v: Evicted = %v, want %v", tt.name, got, tt.wantEvicted)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	r := NewRecorder(time.Second, 10)
+	if w := r.Window("a", at(0), at(10)); w != nil {
+		t.Errorf("Window of unknown ID = %v, want nil", w)
+	}
+	if b := r.Total("a"); b.N != 0 {
+		t.Errorf("Total of unknown ID = %+v, want empty", b)
+	}
+	if n := r.Evicted("a"); n != 0 {
+		t.Errorf("Evicted of unknown ID = %v, want 0", n)
+	}
+	if ids := r.IDs(); len(ids) != 0 {
+		t.Errorf("IDs = %v, want none", ids)
+	}
+	if !r.LastActivity().IsZero() {
+		t.Errorf("LastActivity = %v, want zero", r.LastActivity())
+	}
+	c := NewCursor()
+	if ps := r.Collect(c, at(10)); len(ps) != 0 {
+		t.Errorf("Collect = %v, want none", ps)
+	}
+	if ps := r.Flush(c); len(ps) != 0 {
+		t.Errorf("Flush = %v, want none", ps)
+	}
+	if r.Close("a") {
+		t.Errorf("Close of unknown ID = true, want false")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	r := NewRecorder(time.Second, 10)
+	c := NewCursor()
+	r.Add("a", at(0.5), 1)
+	r.Add("a", at(2.5), 1)
+	r.Add("b", at(1.5), 1)
+
+	steps := []struct {
+		name      string
+		flush     bool
+		until     float64
+		add       float64
+		wantN     []int64
+		wantStart []int64
+	}{
+		// 記録中の区間（a の 2 秒目）は返さない
+		{"first", false, 1.5, -1, []int64{1}, []int64{0}},
+		// 値の無い区間も 0 件として返し、b は最初に値が記録された区間から返す
+		{"catch up", false, 3, -1, []int64{0, 1, 1, 0}, []int64{1, 2, 1, 2}},
+		{"nothing new", false, 3, -1, []int64{}, []int64{}},
+		// 値が途絶えても、完了した区間を 0 件として返す
+		{"idle", false, 5, -1, []int64{0, 0, 0, 0}, []int64{3, 4, 3, 4}},
+		{"add", false, 6.5, 6.2, []int64{0, 0}, []int64{5, 5}},
+		// Flush は記録中の区間も返す
+		{"flush", true, 0, -1, []int64{1}, []int64{6}},
+		{"flush again", true, 0, -1, []int64{}, []int64{}},
+	}
+	for _, s := range steps {
+		if s.add >= 0 {
+			r.Add("a", at(s.add), 1)
+		}
+		var ps []Point
+		if s.flush {
+			ps = r.Flush(c)
+		} else {
+			ps = r.Collect(c, at(s.until))
+		}
+		n, start := pointCounts(ps)
+		if !equal(n, s.wantN) || !equal(start, s.wantStart) {
+			t.Errorf("%v: got %v (start %v), want %v (start %v)", s.name, n, start, s.wantN, s.wantStart)
+		}
+	}
+}
+
+func TestCollectIndependentCursors(t *testing.T) {
+	r := NewRecorder(time.Second, 10)
+	r.Add("a", at(0.5), 1)
+	r.Add("a", at(1.5), 1)
+	c1, c2 := NewCursor(), NewCursor()
+	if n, _ := pointCounts(r.Collect(c1, at(2))); !equal(n, []int64{1, 1}) {
+		t.Errorf("Collect(c1) = %v, want [1 1]", n)
+	}
+	if n, _ := pointCounts(r.Collect(c2, at(2))); !equal(n, []int64{1, 1}) {
+		t.Errorf("Collect(c2) = %v, want [1 1]", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	r := NewRecorder(time.Second, 10)
+	c := NewCursor()
+	r.Add("a", at(0.5), 1)
+	r.Add("b", at(0.5), 1)
+	if !r.Close("a") {
+		t.Fatalf("Close = false, want true")
+	}
+	if r.Close("a") {
+		t.Errorf("second Close = true, want false")
+	}
+	if r.Add("a", at(1.5), 1) {
+		t.Errorf("Add after Close = true, want false")
+	}
+	if !r.IsClosed("a") || r.IsClosed("b") {
+		t.Errorf("IsClosed(a, b) = %v, %v, want true, false", r.IsClosed("a"), r.IsClosed("b"))
+	}
+	// 終了した ID は、終了後の区間を 0 件で埋めない
+	_, start := pointCounts(r.Collect(c, at(5)))
+	if !equal(start, []int64{0, 0, 1, 2, 3, 4}) {
+		t.Errorf("Collect after Close = start %v, want [0 0 1 2 3 4]", start)
+	}
+	r.CloseAll()
+	if r.Add("b", at(6), 1) {
+		t.Errorf("Add after CloseAll = true, want false")
+	}
+}
+
+func TestWriters(t *testing.T) {
+	r := NewRecorder(time.Second, 10)
+	w1, w2 := r.NewWriter(), r.NewWriter()
+	w1.Add("a", at(0.1), 3)
+	w2.Add("a", at(0.2), 7)
+	w1.Add("a", at(1.1), 1)
+	r.Add("a", at(1.2), 9)
+	n, _ := counts(r.Window("a", at(0), at(2)))
+	if !equal(n, []int64{2, 2}) {
+		t.Errorf("Window = %v, want [2 2]", n)
+	}
+	if b := r.Total("a"); b.N != 4 || b.Sum != 20 || b.Min != 1 || b.Max != 9 {
+		t.Errorf("Total = %+v, want N=4 Sum=20 Min=1 Max=9", b)
+	}
+	if got := r.LastActivity(); !got.Equal(at(1.2)) {
+		t.Errorf("LastActivity = %v, want %v", got, at(1.2))
+	}
+}
+
+func TestRecordersSetEnd(t *testing.T) {
+	rs := NewRecorders(2, time.Second, 10)
+	// 区分 0 は 2.5 秒で終わる（2 秒目の区間までを返す）
+	rs.SetEnd(0, at(2.5))
+	rs.Get(0).Add("a", at(0.5), 1)
+	rs.Get(0).Add("a", at(2.2), 1)
+	rs.Get(1).Add("a", at(2.7), 1)
+
+	steps := []struct {
+		until float64
+		want  [][]int64
+	}{
+		{2, [][]int64{{1, 0}, {}}},
+		// 終了後も区分 0 の区間を 0 件で埋めない
+		{6, [][]int64{{1}, {1, 0, 0, 0}}},
+		{9, [][]int64{{}, {0, 0, 0}}},
+	}
+	for _, s := range steps {
+		points := rs.Collect(at(s.until))
+		for i, ps := range points {
+			if n, _ := pointCounts(ps); !equal(n, s.want[i]) {
+				t.Errorf("until %v: Collect[%v] = %v, want %v", s.until, i, n, s.want[i])
+			}
+		}
+	}
+}
+
+func BenchmarkWriterAdd(b *testing.B) {
+	ids := []string{"a", "b", "c", "d"}
+	b.Run("sharded", func(b *testing.B) {
+		r := NewRecorder(time.Second, 3600)
+		b.RunParallel(func(pb *testing.PB) {
+			w := r.NewWriter()
+			for i := 0; pb.Next(); i++ {
+				w.Add(ids[i%len(ids)], time.Now(), int64(i))
+			}
+		})
+	})
+	b.Run("shared", func(b *testing.B) {
+		r := NewRecorder(time.Second, 3600)
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				r.Add(ids[i%len(ids)], time.Now(), int64(i))
+			}
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package timeseries
+
+import "time"
+
+// Recorders は区分（計測の段階など）ごとに分けた Recorder と、それぞれの読み出し位置
+type Recorders struct {
+	recorders []*Recorder
+	cursors   []*Cursor
+	// ends は区分ごとの終了時刻（ゼロ値の場合は終了しない）
+	ends []time.Time
+}
+
+// NewRecorders は n 個の区分それぞれに NewRecorder(interval, capacity) を生成する
+func NewRecorders(n int, interval time.Duration, capacity int) *Recorders {
+	rs := &Recorders{}
+	for i := 0; i < n; i++ {
+		rs.recorders = append(rs.recorders, NewRecorder(interval, capacity))
+		rs.cursors = append(rs.cursors, NewCursor())
+	}
+	rs.ends = make([]time.Time, n)
+	return rs
+}
+
+// Get は区分 i の Recorder を返す
+func (rs *Recorders) Get(i int) *Recorder {
+	return rs.recorders[i]
+}
+
+// Writers は区分ごとの Writer を追加して返す
+func (rs *Recorders) Writers() []*Writer {
+	ws := make([]*Writer, len(rs.recorders))
+	for i, r := range rs.recorders {
+		ws[i] = r.NewWriter()
+	}
+	return ws
+}
+
+// CloseAll は全ての区分の記録を終了する
+func (rs *Recorders) CloseAll() {
+	for _, r := range rs.recorders {
+		r.CloseAll()
+	}
+}
+
+// SetEnd は区分 i が時刻 t で終わることを設定する
+// Collect は t を含む区間より後の区間を、値の無い区間として返さない
+func (rs *Recorders) SetEnd(i int, t time.Time) {
+	rs.ends[i] = t
+}
+
+// Collect は区分ごとに Recorder.Collect を呼び出した結果を返す
+func (rs *Recorders) Collect(until time.Time) [][]Point {
+	points := make([][]Point, len(rs.recorders))
+	for i, r := range rs.recorders {
+		u := until
+		if end := rs.ends[i]; !end.IsZero() && u.After(end.Add(r.Interval())) {
+			u = end.Add(r.Interval())
+		}
+		points[i] = r.Collect(rs.cursors[i], u)
+	}
+	return points
+}
+
+// Flush は区分ごとに Recorder.Flush を呼び出した結果を返す
+func (rs *Recorders) Flush() [][]Point {
+	points := make([][]Point, len(rs.recorders))
+	for i, r := range rs.recorders {
+		points[i] = r.Flush(rs.cursors[i])
+	}
+	return points
+}
+
+// LastActivity は全ての区分のうち、最後に値が記録された時刻を返す
+func (rs *Recorders) LastActivity() time.Time {
+	last := time.Time{}
+	for _, r := range rs.recorders {
+		if t := r.LastActivity(); t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
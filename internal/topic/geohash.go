@@ -0,0 +1,215 @@
+package topic
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/golang/geo/r1"
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+// geohashMaxLevel は根の 1 文字を除いた geohash の最大文字数
+const geohashMaxLevel = 11
+
+const geohashChars = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+var geohashAlphabet = strings.Split(geohashChars, "")
+
+func init() {
+	register("geohash", geohashMaxLevel, geohashChars, func(l Layout) Scheme { return &Geohash{layout: l} })
+}
+
+// Geohash は geohash の 1 文字目を根、2 文字目以降の 1 文字ずつを位置とする方式
+type Geohash struct {
+	layout Layout
+}
+
+func (g *Geohash) Name() string {
+	return "geohash"
+}
+
+func (g *Geohash) Layout() Layout {
+	return g.layout
+}
+
+func (g *Geohash) Topic(ll s2.LatLng) string {
+	hash := geohashEncode(ll.Lat.Degrees(), ll.Lng.Degrees(), g.layout.Level+1)
+	return g.layout.join(hash[:1], strings.Split(hash[1:], ""))
+}
+
+func (g *Geohash) Region(topic string) (s2.Region, error) {
+	hash, err := g.hash(topic)
+	if err != nil {
+		return nil, err
+	}
+	rect, _ := geohashDecode(hash)
+	return rect, nil
+}
+
+// hash はトピック名を geohash に変換する
+func (g *Geohash) hash(topic string) (string, error) {
+	root, tokens, err := g.layout.split(topic, 1, 1)
+	if err != nil {
+		return "", err
+	}
+	hash := root[0] + strings.Join(tokens, "")
+	if len(root[0]) != 1 {
+		return "", TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+	}
+	if _, ok := geohashDecode(hash); !ok {
+		return "", TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+	}
+	return hash, nil
+}
+
+func (g *Geohash) Area(prefix string) (Area, error) {
+	if prefix == "" {
+		prefix = "/s"
+	}
+	hash, err := g.hash(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return geohashArea(hash), nil
+}
+
+// geohashArea は geohash の領域を範囲とする
+type geohashArea string
+
+func (a geohashArea) Locate(t uint64, level int) s2.LatLng {
+	if level > geohashMaxLevel {
+		level = geohashMaxLevel
+	}
+	hash := []byte(a)
+	for i := uint(0); len(hash) < level+1; i++ {
+		hash = append(hash, geohashChars[t>>(59-i*5)&31])
+	}
+	rect, _ := geohashDecode(string(hash))
+	return rect.Center()
+}
+
+func (g *Geohash) Filters(area s2.Cap, level int) ([]string, error) {
+	if level > g.layout.Level {
+		level = g.layout.Level
+	}
+	precision := level + 1
+	latBits := uint(5 * precision / 2)
+	lngBits := uint(5*precision) - latBits
+	cells, err := gridCells(area, 180./float64(uint64(1)<<latBits), 360./float64(uint64(1)<<lngBits))
+	if err != nil {
+		return nil, err
+	}
+	prefixes := []prefix{}
+	for _, c := range cells {
+		hash := geohashEncode(c.Center().Lat.Degrees(), c.Center().Lng.Degrees(), precision)
+		prefixes = append(prefixes, prefix{root: hash[:1], tokens: strings.Split(hash[1:], "")[:level]})
+	}
+	return g.layout.filters(prefixes, geohashAlphabet)
+}
+
+func (g *Geohash) All() []string {
+	filters := []string{}
+	for _, c := range geohashAlphabet {
+		filters = append(filters, "/"+c+"/#")
+	}
+	return filters
+}
+
+func geohashEncode(lat, lng float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lngRange := [2]float64{-180, 180}
+	var sb strings.Builder
+	even := true
+	ch, bit := 0, 0
+	for sb.Len() < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lngRange, lng
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			sb.WriteByte(geohashChars[ch])
+			ch, bit = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+func geohashDecode(hash string) (s2.Rect, bool) {
+	latRange := [2]float64{-90, 90}
+	lngRange := [2]float64{-180, 180}
+	even := true
+	for _, c := range hash {
+		ch := strings.IndexRune(geohashChars, c)
+		if ch < 0 {
+			return s2.Rect{}, false
+		}
+		for i := 4; i >= 0; i-- {
+			r := &latRange
+			if even {
+				r = &lngRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if ch>>uint(i)&1 == 1 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return degreeRect(latRange[0], latRange[1], lngRange[0], lngRange[1]), true
+}
+
+// degreeRect は緯度経度[度]の範囲を s2.Rect にする
+func degreeRect(latLo, latHi, lngLo, lngHi float64) s2.Rect {
+	return s2.Rect{
+		Lat: r1.Interval{Lo: latLo * math.Pi / 180, Hi: latHi * math.Pi / 180},
+		Lng: s1.IntervalFromEndpoints(lngLo*math.Pi/180, lngHi*math.Pi/180),
+	}
+}
+
+// gridCells は緯度 dLat[度]、経度 dLng[度] 間隔の格子のうち、area と重なるマスを返す
+func gridCells(area s2.Cap, dLat, dLng float64) ([]s2.Rect, error) {
+	bound := area.RectBound()
+	latLo := int64(math.Floor((bound.Lat.Lo*180/math.Pi + 90) / dLat))
+	latHi := int64(math.Floor((bound.Lat.Hi*180/math.Pi + 90) / dLat))
+	if last := int64(math.Round(180/dLat)) - 1; latHi > last {
+		latHi = last
+	}
+	nLng := int64(math.Round(360 / dLng))
+	lngLo, lngHi := int64(0), nLng-1
+	if !bound.Lng.IsFull() {
+		lngLo = int64(math.Floor((bound.Lng.Lo*180/math.Pi + 180) / dLng))
+		lngHi = int64(math.Floor((bound.Lng.Hi*180/math.Pi + 180) / dLng))
+		if lngHi < lngLo {
+			// 経度 180 度をまたぐ
+			lngHi += nLng
+		}
+	}
+	if n := (latHi - latLo + 1) * (lngHi - lngLo + 1); n > maxFilters {
+		return nil, FilterLimitError{fmt.Sprintf("Too many topic filters (%v or more, limit: %v)", n, maxFilters)}
+	}
+	center := s2.LatLngFromPoint(area.Center())
+	cells := []s2.Rect{}
+	for i := latLo; i <= latHi; i++ {
+		for j := lngLo; j <= lngHi; j++ {
+			k := j % nLng
+			r := degreeRect(float64(i)*dLat-90, float64(i+1)*dLat-90, float64(k)*dLng-180, float64(k+1)*dLng-180)
+			if r.DistanceToLatLng(center) <= area.Radius() {
+				cells = append(cells, r)
+			}
+		}
+	}
+	return cells, nil
+}
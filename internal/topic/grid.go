@@ -0,0 +1,159 @@
+package topic
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+
+	"github.com/golang/geo/s2"
+)
+
+// gridMaxLevel は緯度経度の小数点以下の最大桁数
+const gridMaxLevel = 7
+
+var gridAlphabet = func() []string {
+	a := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		a = append(a, fmt.Sprintf("%02d", i))
+	}
+	return a
+}()
+
+func init() {
+	register("grid", gridMaxLevel, "0123456789", func(l Layout) Scheme { return &Grid{layout: l} })
+}
+
+// Grid は緯度と経度の 1 度単位のマスを根 ("<緯度+90>/<経度+180>")、
+// 小数点以下の緯度と経度の各桁を並べた 2 文字を位置とする方式
+type Grid struct {
+	layout Layout
+}
+
+func (g *Grid) Name() string {
+	return "grid"
+}
+
+func (g *Grid) Layout() Layout {
+	return g.layout
+}
+
+func (g *Grid) Topic(ll s2.LatLng) string {
+	scale := pow10(g.layout.Level)
+	lat := int64(math.Floor((ll.Lat.Degrees() + 90) * float64(scale)))
+	if lat >= 180*scale {
+		// 北極点は最も北のマスに含める
+		lat = 180*scale - 1
+	}
+	lng := int64(math.Floor((ll.Lng.Degrees()+180)*float64(scale))) % (360 * scale)
+	if lng < 0 {
+		lng += 360 * scale
+	}
+	root, tokens := gridTokens(lat, lng, g.layout.Level)
+	return g.layout.join(root, tokens)
+}
+
+// gridTokens は小数点以下 level 桁の精度の緯度・経度のマス番号を根と位置にする
+func gridTokens(lat, lng int64, level int) (string, []string) {
+	scale := pow10(level)
+	tokens := make([]string, 0, level)
+	for i := level - 1; i >= 0; i-- {
+		d := pow10(i)
+		tokens = append(tokens, fmt.Sprintf("%d%d", lat/d%10, lng/d%10))
+	}
+	return fmt.Sprintf("%d/%d", lat/scale, lng/scale), tokens
+}
+
+func (g *Grid) Region(topic string) (s2.Region, error) {
+	a, err := g.cell(topic)
+	if err != nil {
+		return nil, err
+	}
+	return a.rect(), nil
+}
+
+// cell はトピック名を小数点以下 level 桁の精度のマス番号に変換する
+func (g *Grid) cell(topic string) (gridArea, error) {
+	root, tokens, err := g.layout.split(topic, 2, 2)
+	if err != nil {
+		return gridArea{}, err
+	}
+	lat, err1 := strconv.ParseInt(root[0], 10, 64)
+	lng, err2 := strconv.ParseInt(root[1], 10, 64)
+	if err1 != nil || err2 != nil || lat < 0 || lat >= 180 || lng < 0 || lng >= 360 {
+		return gridArea{}, TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+	}
+	for _, t := range tokens {
+		if t[0] < '0' || t[0] > '9' || t[1] < '0' || t[1] > '9' {
+			return gridArea{}, TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+		}
+		lat = lat*10 + int64(t[0]-'0')
+		lng = lng*10 + int64(t[1]-'0')
+	}
+	return gridArea{lat: lat, lng: lng, level: len(tokens)}, nil
+}
+
+func (g *Grid) Area(prefix string) (Area, error) {
+	if prefix == "" {
+		prefix = "/90/180"
+	}
+	return g.cell(prefix)
+}
+
+// gridArea は小数点以下 level 桁の精度のマスを範囲とする
+type gridArea struct {
+	lat, lng int64
+	level    int
+}
+
+func (a gridArea) rect() s2.Rect {
+	d := 1 / float64(pow10(a.level))
+	return degreeRect(float64(a.lat)*d-90, float64(a.lat+1)*d-90, float64(a.lng)*d-180, float64(a.lng+1)*d-180)
+}
+
+func (a gridArea) Locate(t uint64, level int) s2.LatLng {
+	if level > gridMaxLevel {
+		level = gridMaxLevel
+	}
+	for ; a.level < level; a.level++ {
+		// t を [0, 1) の小数とみなし、上位から 100 進数の 1 桁ずつを緯度と経度の次の桁にする
+		hi, lo := bits.Mul64(t, 100)
+		t = lo
+		a.lat = a.lat*10 + int64(hi/10)
+		a.lng = a.lng*10 + int64(hi%10)
+	}
+	return a.rect().Center()
+}
+
+func (g *Grid) Filters(area s2.Cap, level int) ([]string, error) {
+	if level > g.layout.Level {
+		level = g.layout.Level
+	}
+	d := 1 / float64(pow10(level))
+	cells, err := gridCells(area, d, d)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := []prefix{}
+	for _, c := range cells {
+		// 境界上の誤差を避けるため、マスの中心から番号を求める
+		lat := int64(math.Floor((c.Center().Lat.Degrees() + 90) / d))
+		lng := int64(math.Floor((c.Center().Lng.Degrees() + 180) / d))
+		root, tokens := gridTokens(lat, lng, level)
+		prefixes = append(prefixes, prefix{root: root, tokens: tokens})
+	}
+	return g.layout.filters(prefixes, gridAlphabet)
+}
+
+func (g *Grid) All() []string {
+	// "/signal" にマッチしないよう、根の 2 階層を必須にする
+	return []string{"/+/+/#"}
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
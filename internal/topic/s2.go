@@ -0,0 +1,127 @@
+package topic
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/golang/geo/s2"
+)
+
+// MaxLevel は S2 セルの最大レベル
+const MaxLevel = 30
+
+var s2Alphabet = []string{"0", "1", "2", "3"}
+
+func init() {
+	register("s2", MaxLevel, "0123456789", func(l Layout) Scheme { return &S2{layout: l} })
+}
+
+// S2 は面番号を根、子セルの位置 (0-3) を位置とする方式
+// 既定のレイアウトのトピック名は根を含めて 31 階層で、時刻のビット列をそのまま 32 階層に並べていた以前の single-publisher のトピック名とは
+// 階層数と長さが異なる（階層数の影響を比べる場合は -sweepDepth を使う）
+type S2 struct {
+	layout Layout
+}
+
+// Default は子セルの位置 1 つを 1 階層とする S2 方式
+var Default = &S2{layout: Layout{Level: MaxLevel, Depth: MaxLevel}}
+
+func (s *S2) Name() string {
+	return "s2"
+}
+
+func (s *S2) Layout() Layout {
+	return s.layout
+}
+
+func (s *S2) Topic(ll s2.LatLng) string {
+	return s.CellTopic(s2.CellIDFromLatLng(ll))
+}
+
+// CellTopic は id をトピック名に変換する
+// id が Level より深い場合は Level の祖先セルを、浅い場合は id のレベルまでを表すトピック名を返す
+func (s *S2) CellTopic(id s2.CellID) string {
+	if id.Level() > s.layout.Level {
+		id = id.Parent(s.layout.Level)
+	}
+	return s.layout.join(strconv.Itoa(id.Face()), s2Tokens(id))
+}
+
+func s2Tokens(id s2.CellID) []string {
+	tokens := make([]string, 0, id.Level())
+	for level := 1; level <= id.Level(); level++ {
+		tokens = append(tokens, s2Alphabet[id.ChildPosition(level)])
+	}
+	return tokens
+}
+
+// CellID はトピック名をセル ID に変換する
+func (s *S2) CellID(topic string) (s2.CellID, error) {
+	root, tokens, err := s.layout.split(topic, 1, 1)
+	if err != nil {
+		return 0, err
+	}
+	face, err := strconv.Atoi(root[0])
+	if err != nil || face < 0 || face > 5 {
+		return 0, TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+	}
+	id := s2.CellIDFromFace(face)
+	for _, t := range tokens {
+		pos, err := strconv.Atoi(t)
+		if err != nil || pos < 0 || pos > 3 {
+			return 0, TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+		}
+		id = id.Children()[pos]
+	}
+	return id, nil
+}
+
+func (s *S2) Region(topic string) (s2.Region, error) {
+	id, err := s.CellID(topic)
+	if err != nil {
+		return nil, err
+	}
+	return s2.CellFromCellID(id), nil
+}
+
+func (s *S2) Area(prefix string) (Area, error) {
+	if prefix == "" {
+		prefix = "/0"
+	}
+	id, err := s.CellID(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return s2Area(id), nil
+}
+
+// s2Area はセルを範囲とする
+type s2Area s2.CellID
+
+func (a s2Area) Locate(t uint64, level int) s2.LatLng {
+	return s2.LatLngFromPoint(Descend(s2.CellID(a), t, level).Point())
+}
+
+func (s *S2) Filters(area s2.Cap, level int) ([]string, error) {
+	if level > s.layout.Level {
+		level = s.layout.Level
+	}
+	// 固定レベルの被覆はセル数に比例して時間がかかるため、先にセル数を見積もる
+	if n := area.Area() / s2.AvgAreaMetric.Value(level); n > maxFilters {
+		return nil, FilterLimitError{fmt.Sprintf("Too many topic filters (about %.0f, limit: %v)", n, maxFilters)}
+	}
+	rc := &s2.RegionCoverer{MinLevel: level, MaxLevel: level, MaxCells: maxFilters}
+	prefixes := []prefix{}
+	for _, id := range rc.Covering(area) {
+		prefixes = append(prefixes, prefix{root: strconv.Itoa(id.Face()), tokens: s2Tokens(id)})
+	}
+	return s.layout.filters(prefixes, s2Alphabet)
+}
+
+func (s *S2) All() []string {
+	filters := []string{}
+	for face := 0; face < 6; face++ {
+		filters = append(filters, "/"+strconv.Itoa(face)+"/#")
+	}
+	return filters
+}
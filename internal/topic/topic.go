@@ -0,0 +1,267 @@
+// Package topic は位置とトピック名を相互に変換する
+// トピック名は "/<根>/<位置>/<位置>/..." の形式で、根と位置の表し方は方式 (Scheme) ごとに異なる
+package topic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/geo/s2"
+)
+
+// maxFilters は Filters が生成するトピックフィルタ数の上限
+const maxFilters = 1 << 16
+
+// Scheme はトピック名の方式
+type Scheme interface {
+	Name() string
+	Layout() Layout
+	// Topic は ll を含む領域のトピック名を返す
+	Topic(ll s2.LatLng) string
+	// Region はトピック名が表す領域を返す
+	Region(topic string) (s2.Region, error)
+	// Filters は area と重なる level の領域のトピックと、その下の全トピックにマッチするトピックフィルタを返す
+	Filters(area s2.Cap, level int) ([]string, error)
+	// All は全てのトピックにマッチするトピックフィルタを返す
+	All() []string
+	// Area は prefix のトピック名が表す領域を、位置を選ぶ範囲として返す
+	// prefix が空の場合は方式ごとの既定の範囲（緯度 0 度、経度 0 度付近）とする
+	Area(prefix string) (Area, error)
+}
+
+// Area は位置を選ぶ範囲
+type Area interface {
+	// Locate は t の上位の桁から順に子の領域を選んで level まで降りた領域の中心を返す
+	// level が範囲のレベル以下の場合は範囲の中心、方式の最大レベルを超える場合は最大レベルの領域の中心を返す
+	Locate(t uint64, level int) s2.LatLng
+}
+
+type scheme struct {
+	maxLevel int
+	// reserved は区切り文字に使えない文字
+	reserved string
+	new      func(l Layout) Scheme
+}
+
+var schemes = map[string]scheme{}
+
+func register(name string, maxLevel int, reserved string, new func(l Layout) Scheme) {
+	schemes[name] = scheme{maxLevel: maxLevel, reserved: reserved, new: new}
+}
+
+// Names は利用可能な方式の名前を返す
+func Names() []string {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewScheme は name の方式を生成する
+// level が負の場合は方式ごとの最大レベル、depth が 0 以下の場合は level と同じ階層数とする
+func NewScheme(name string, level, depth int, separator string) (Scheme, error) {
+	s, ok := schemes[name]
+	if !ok {
+		return nil, TopicError{fmt.Sprintf("Unknown topic scheme (inputed scheme: %v, available: %v)", name, strings.Join(Names(), ", "))}
+	}
+	if level < 0 {
+		level = s.maxLevel
+	}
+	if level > s.maxLevel {
+		return nil, TopicError{fmt.Sprintf("Topic level must not exceed %v (inputed level: %v, scheme: %v)", s.maxLevel, level, name)}
+	}
+	if strings.ContainsAny(separator, s.reserved) {
+		return nil, TopicError{fmt.Sprintf("Invalid topic separator (inputed separator: %v, scheme: %v)", separator, name)}
+	}
+	l, err := NewLayout(level, depth, separator)
+	if err != nil {
+		return nil, err
+	}
+	return s.new(l), nil
+}
+
+// Layout はトピック名の階層構造
+// 根の下に Level 個の位置を Depth 階層に分けて並べる
+type Layout struct {
+	Level int
+	Depth int
+	// Separator は同じ階層に並べる位置同士の区切り文字
+	Separator string
+}
+
+// NewLayout は Layout を生成する
+// depth が 0 以下の場合は level と同じ階層数とする
+func NewLayout(level, depth int, separator string) (Layout, error) {
+	if level < 0 {
+		return Layout{}, TopicError{fmt.Sprintf("Invalid topic level (inputed level: %v)", level)}
+	}
+	if depth <= 0 {
+		depth = level
+	}
+	if depth > level {
+		return Layout{}, TopicError{fmt.Sprintf("Topic depth must not exceed topic level (inputed depth: %v, level: %v)", depth, level)}
+	}
+	if strings.ContainsAny(separator, "/#+") {
+		return Layout{}, TopicError{fmt.Sprintf("Invalid topic separator (inputed separator: %v)", separator)}
+	}
+	return Layout{Level: level, Depth: depth, Separator: separator}, nil
+}
+
+// String はレイアウトを表示用の文字列にする
+func (l Layout) String() string {
+	return fmt.Sprintf("level=%v depth=%v separator=%q", l.Level, l.Depth, l.Separator)
+}
+
+// boundaries は各階層の末尾に来る位置のレベルを返す
+// 割り切れない場合は上の階層に多く割り当てる
+func (l Layout) boundaries() []int {
+	result := make([]int, 0, l.Depth)
+	level := 0
+	for i := 0; i < l.Depth; i++ {
+		n := l.Level / l.Depth
+		if i < l.Level%l.Depth {
+			n++
+		}
+		level += n
+		result = append(result, level)
+	}
+	return result
+}
+
+// boundary は level 以上で最も浅い階層の区切りのレベルを返す
+func (l Layout) boundary(level int) int {
+	if level <= 0 {
+		return 0
+	}
+	for _, b := range l.boundaries() {
+		if b >= level {
+			return b
+		}
+	}
+	return l.Level
+}
+
+// join は根と位置をトピック名にする
+func (l Layout) join(root string, tokens []string) string {
+	var sb strings.Builder
+	sb.WriteString("/")
+	sb.WriteString(root)
+	b := l.boundaries()
+	for i, j := 1, 0; i <= len(tokens); i++ {
+		if i == 1 || i > b[j] {
+			if i > 1 {
+				j++
+			}
+			sb.WriteString("/")
+		} else {
+			sb.WriteString(l.Separator)
+		}
+		sb.WriteString(tokens[i-1])
+	}
+	return sb.String()
+}
+
+// split はトピック名を rootSegments 階層分の根と、1 つ width 文字の位置に分ける
+func (l Layout) split(topic string, rootSegments, width int) ([]string, []string, error) {
+	segments := strings.Split(strings.TrimPrefix(topic, "/"), "/")
+	if len(segments) < rootSegments {
+		return nil, nil, TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+	}
+	tokens := []string{}
+	for _, s := range segments[rootSegments:] {
+		if l.Separator != "" {
+			tokens = append(tokens, strings.Split(s, l.Separator)...)
+			continue
+		}
+		if len(s)%width != 0 {
+			return nil, nil, TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+		}
+		for i := 0; i < len(s); i += width {
+			tokens = append(tokens, s[i:i+width])
+		}
+	}
+	for _, t := range tokens {
+		if len(t) != width {
+			return nil, nil, TopicError{fmt.Sprintf("Invalid topic name (inputed topic name: %v)", topic)}
+		}
+	}
+	if len(tokens) > l.Level {
+		return nil, nil, TopicError{fmt.Sprintf("Topic name is deeper than topic level %v (inputed topic name: %v)", l.Level, topic)}
+	}
+	return segments[:rootSegments], tokens, nil
+}
+
+// prefix はトピックフィルタの元になる根と位置
+type prefix struct {
+	root   string
+	tokens []string
+}
+
+// filters は prefixes を階層の区切りまで alphabet で展開したトピックフィルタを返す
+func (l Layout) filters(prefixes []prefix, alphabet []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, p := range prefixes {
+		end := l.boundary(len(p.tokens))
+		n := 1
+		for i := len(p.tokens); i < end && n <= maxFilters; i++ {
+			n *= len(alphabet)
+		}
+		if len(result)+n > maxFilters {
+			return nil, FilterLimitError{fmt.Sprintf("Too many topic filters (%v or more, limit: %v)", len(result)+n, maxFilters)}
+		}
+		for _, tokens := range expand(p.tokens, end, alphabet) {
+			f := l.join(p.root, tokens) + "/#"
+			if !seen[f] {
+				seen[f] = true
+				result = append(result, f)
+			}
+		}
+	}
+	return result, nil
+}
+
+func expand(tokens []string, end int, alphabet []string) [][]string {
+	if len(tokens) >= end {
+		return [][]string{tokens}
+	}
+	result := [][]string{}
+	for _, a := range alphabet {
+		next := append(append([]string{}, tokens...), a)
+		result = append(result, expand(next, end, alphabet)...)
+	}
+	return result
+}
+
+// Descend は parent から、t の上位ビットから 2 ビットずつを子セルの位置として level まで降りたセルを返す
+func Descend(parent s2.CellID, t uint64, level int) s2.CellID {
+	if level > MaxLevel {
+		level = MaxLevel
+	}
+	id := parent
+	for i := 0; id.Level() < level; i++ {
+		id = id.Children()[(t>>uint(62-i*2))&3]
+	}
+	return id
+}
+
+// TopicError はトピック名の変換に失敗したことを表す
+type TopicError struct {
+	Msg string
+}
+
+func (e TopicError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
+
+// FilterLimitError は生成するトピックフィルタ数が上限を超えたことを表す
+type FilterLimitError struct {
+	Msg string
+}
+
+func (e FilterLimitError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
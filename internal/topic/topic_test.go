@@ -0,0 +1,270 @@
+package topic
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/geocheck"
+)
+
+func TestTopic(t *testing.T) {
+	ll := s2.LatLngFromDegrees(57.64911, 10.40744)
+	tests := []struct {
+		scheme string
+		level  int
+		depth  int
+		sep    string
+		want   string
+	}{
+		{"geohash", 10, 0, "", "/u/4/p/r/u/y/d/q/q/v/j"},
+		{"geohash", 10, 2, "", "/u/4pruy/dqqvj"},
+		{"geohash", 10, 2, "-", "/u/4-p-r-u-y/d-q-q-v-j"},
+		{"geohash", 10, 3, "", "/u/4pru/ydq/qvj"},
+		{"geohash", 3, 0, "", "/u/4/p/r"},
+		{"geohash", 0, 0, "", "/u"},
+		{"grid", 2, 0, "", "/147/190/64/40"},
+		{"grid", 2, 1, "", "/147/190/6440"},
+		{"grid", 2, 1, ".", "/147/190/64.40"},
+		{"grid", 4, 2, "_", "/147/190/64_40/97_14"},
+		{"grid", 0, 0, "", "/147/190"},
+	}
+	for _, tt := range tests {
+		s, err := NewScheme(tt.scheme, tt.level, tt.depth, tt.sep)
+		if err != nil {
+			t.Fatalf("NewScheme(%v, %v, %v, %q): %v", tt.scheme, tt.level, tt.depth, tt.sep, err)
+		}
+		if got := s.Topic(ll); got != tt.want {
+			t.Errorf("%v %v: Topic = %v, want %v", tt.scheme, s.Layout(), got, tt.want)
+		}
+	}
+}
+
+func TestS2Topic(t *testing.T) {
+	ll := s2.LatLngFromDegrees(35.681167, 139.767052)
+	id := s2.CellIDFromLatLng(ll)
+	tests := []struct {
+		level int
+		depth int
+		sep   string
+	}{
+		{30, 0, ""},
+		{30, 3, ""},
+		{30, 3, "."},
+		{17, 5, "-"},
+		{1, 1, ""},
+		{0, 0, ""},
+	}
+	for _, tt := range tests {
+		s, err := NewScheme("s2", tt.level, tt.depth, tt.sep)
+		if err != nil {
+			t.Fatalf("NewScheme(s2, %v, %v, %q): %v", tt.level, tt.depth, tt.sep, err)
+		}
+		topic := s.Topic(ll)
+		depth := tt.depth
+		if depth == 0 {
+			depth = tt.level
+		}
+		if got := strings.Count(topic, "/") - 1; got != depth {
+			t.Errorf("%v: %v has %v segments, want %v", s.Layout(), topic, got, depth)
+		}
+		got, err := s.(*S2).CellID(topic)
+		if err != nil {
+			t.Fatalf("%v: CellID(%v): %v", s.Layout(), topic, err)
+		}
+		if want := id.Parent(tt.level); got != want {
+			t.Errorf("%v: CellID(%v) = %v, want %v", s.Layout(), topic, got, want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		scheme string
+		level  int
+		depth  int
+		sep    string
+	}{
+		{"s2", -1, 0, ""},
+		{"s2", 20, 4, ""},
+		{"s2", 20, 4, "."},
+		{"s2", 7, 2, "_"},
+		{"geohash", -1, 0, ""},
+		{"geohash", 8, 3, ""},
+		{"geohash", 8, 3, "."},
+		{"geohash", 5, 5, "_"},
+		{"grid", -1, 0, ""},
+		{"grid", 5, 2, ""},
+		{"grid", 5, 2, "."},
+		{"grid", 3, 1, "-"},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		s, err := NewScheme(tt.scheme, tt.level, tt.depth, tt.sep)
+		if err != nil {
+			t.Fatalf("NewScheme(%v, %v, %v, %q): %v", tt.scheme, tt.level, tt.depth, tt.sep, err)
+		}
+		for i := 0; i < 200; i++ {
+			ll := randomLatLng(rng)
+			topic := s.Topic(ll)
+			r, err := s.Region(topic)
+			if err != nil {
+				t.Fatalf("%v %v: Region(%v): %v", s.Name(), s.Layout(), topic, err)
+			}
+			if !regionContains(r, ll) {
+				t.Errorf("%v %v: Region(%v) does not contain %v", s.Name(), s.Layout(), topic, ll)
+			}
+			if got := s.Topic(regionCenter(r)); got != topic {
+				t.Errorf("%v %v: Topic of the center of %v = %v", s.Name(), s.Layout(), topic, got)
+			}
+		}
+	}
+}
+
+func TestArea(t *testing.T) {
+	tests := []struct {
+		scheme string
+		prefix string
+		// want は Locate で選んだ位置のトピック名の接頭辞
+		want  string
+		level int
+	}{
+		{"s2", "", "/0", 30},
+		{"s2", "/3/1/2", "/3/1/2/", 30},
+		{"s2", "/3/1/2", "/3/1/2", 2},
+		{"geohash", "", "/s", 11},
+		{"geohash", "/x/n/7", "/x/n/7/", 11},
+		{"geohash", "/x/n/7", "/x/n/7", 1},
+		{"grid", "", "/90/180", 7},
+		{"grid", "/125/319/52", "/125/319/52/", 7},
+		{"grid", "/125/319/52", "/125/319/52", 0},
+	}
+	for _, tt := range tests {
+		s, err := NewScheme(tt.scheme, -1, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := s.Area(tt.prefix)
+		if err != nil {
+			t.Fatalf("%v: Area(%q): %v", tt.scheme, tt.prefix, err)
+		}
+		seen := map[string]bool{}
+		for _, v := range []uint64{0, 1 << 63, 1<<64 - 1, 0x0123456789abcdef, 0xfedcba9876543210} {
+			topic := s.Topic(a.Locate(v, tt.level))
+			if !strings.HasPrefix(topic, tt.want) {
+				t.Errorf("%v: Locate(%x, %v) in %q is %v, want prefix %v", tt.scheme, v, tt.level, tt.prefix, topic, tt.want)
+			}
+			seen[topic] = true
+		}
+		// 範囲より深いレベルでは t ごとに異なる位置を選ぶ
+		if strings.HasSuffix(tt.want, "/") && len(seen) != 5 {
+			t.Errorf("%v: Locate in %q chose %v distinct topics, want 5", tt.scheme, tt.prefix, len(seen))
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		scheme string
+		topic  string
+	}{
+		{"s2", "/6"},
+		{"s2", "/0/4"},
+		{"s2", "/x"},
+		{"geohash", "/a"},
+		{"geohash", "/uu"},
+		{"grid", "/90"},
+		{"grid", "/180/0"},
+		{"grid", "/90/360"},
+		{"grid", "/90/180/5x"},
+		{"grid", "/90/180/123"},
+	}
+	for _, tt := range tests {
+		s, err := NewScheme(tt.scheme, -1, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Region(tt.topic); err == nil {
+			t.Errorf("%v: Region(%v) succeeded, want error", tt.scheme, tt.topic)
+		}
+		if _, err := s.Area(tt.topic); err == nil {
+			t.Errorf("%v: Area(%v) succeeded, want error", tt.scheme, tt.topic)
+		}
+	}
+}
+
+func TestNewScheme(t *testing.T) {
+	tests := []struct {
+		scheme string
+		level  int
+		depth  int
+		sep    string
+		ok     bool
+	}{
+		{"s2", 30, 30, "", true},
+		{"s2", 31, 0, "", false},
+		{"s2", 10, 11, "", false},
+		{"s2", 10, 2, "1", false},
+		{"s2", 10, 2, "/", false},
+		{"geohash", 11, 0, "", true},
+		{"geohash", 12, 0, "", false},
+		{"geohash", 5, 2, "b", false},
+		{"grid", 7, 0, "", true},
+		{"grid", 8, 0, "", false},
+		{"grid", 5, 2, "+", false},
+		{"h3", 5, 0, "", false},
+	}
+	for _, tt := range tests {
+		_, err := NewScheme(tt.scheme, tt.level, tt.depth, tt.sep)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("NewScheme(%v, %v, %v, %q): err = %v, want ok = %v", tt.scheme, tt.level, tt.depth, tt.sep, err, tt.ok)
+		}
+	}
+}
+
+// Covered は円そのものではなく、トピックフィルタにマッチする範囲（円を被覆する領域）を受信範囲とする
+func TestCovered(t *testing.T) {
+	center := s2.LatLngFromDegrees(35.681167, 139.767052)
+	area := s2.CapFromCenterAngle(s2.PointFromLatLng(center), geocheck.KmToAngle(0.1))
+	for _, name := range Names() {
+		s, err := NewScheme(name, -1, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		filters, err := s.Filters(area, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		covered := Covered(s, filters)
+		// 円を被覆する領域のうち、中心が円の外にある領域の中心
+		var inside *s2.LatLng
+		for _, f := range filters {
+			r, err := s.Region(strings.TrimSuffix(f, "/#"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ll := regionCenter(r); !area.ContainsPoint(s2.PointFromLatLng(ll)) {
+				inside = &ll
+				break
+			}
+		}
+		if inside == nil {
+			t.Fatalf("%v: no region of %v has its center outside the circle", name, filters)
+		}
+		tests := []struct {
+			name    string
+			ll      s2.LatLng
+			covered bool
+		}{
+			{"center", center, true},
+			{"covering", *inside, true},
+			{"far", s2.LatLngFromDegrees(-33.8688, 151.2093), false},
+		}
+		for _, tt := range tests {
+			if got := covered(tt.ll.Lat.Degrees(), tt.ll.Lng.Degrees()); got != tt.covered {
+				t.Errorf("%v %v: Covered = %v, want %v", name, tt.name, got, tt.covered)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package topic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+	"location-based-mqtt-evaluation-tool/internal/geocheck"
+)
+
+// VerifyResult は Verify で検査した件数
+type VerifyResult struct {
+	RoundTrip int
+	Filters   int
+	// Skipped はトピックフィルタ数が上限を超えたため検査しなかった件数
+	Skipped int
+}
+
+// Verify は n 個のランダムな地点について、次を検査する
+//   - Topic で得たトピック名の Region がその地点を含み、Region の中心の Topic が同じトピック名になること
+//   - その地点を中心とする円の Filters が、円内の地点の Topic に全てマッチすること
+func Verify(s Scheme, rng *rand.Rand, n int) (VerifyResult, error) {
+	result := VerifyResult{}
+	for i := 0; i < n; i++ {
+		ll := randomLatLng(rng)
+		t := s.Topic(ll)
+		r, err := s.Region(t)
+		if err != nil {
+			return result, err
+		}
+		if !regionContains(r, ll) {
+			return result, TopicError{fmt.Sprintf("Region of %v does not contain %v (scheme: %v)", t, ll, s.Name())}
+		}
+		if c := s.Topic(regionCenter(r)); c != t {
+			return result, TopicError{fmt.Sprintf("Topic of the center of %v is %v (scheme: %v)", t, c, s.Name())}
+		}
+		result.RoundTrip++
+
+		area := s2.CapFromCenterAngle(s2.PointFromLatLng(ll), geocheck.KmToAngle(1+rng.Float64()*49))
+		// トピックフィルタ数が上限を超える場合は、浅いレベルで試し直す
+		var filters []string
+		for level := rng.Intn(s.Layout().Level + 1); level >= 0; level-- {
+			if filters, err = s.Filters(area, level); err == nil {
+				break
+			}
+			if _, ok := err.(FilterLimitError); !ok {
+				return result, err
+			}
+		}
+		if err != nil {
+			result.Skipped++
+			continue
+		}
+		set := map[string]bool{}
+		for _, f := range filters {
+			set[f] = true
+		}
+		for j := 0; j < 10; j++ {
+			p := s2.InterpolateAtDistance(s1.Angle(rng.Float64())*area.Radius(), area.Center(), s2.PointFromLatLng(randomLatLng(rng)))
+			pt := s.Topic(s2.LatLngFromPoint(p))
+			if !matchAny(set, pt) {
+				return result, TopicError{fmt.Sprintf("No topic filter matches %v inside %v (scheme: %v)", pt, area, s.Name())}
+			}
+		}
+		result.Filters++
+	}
+	return result, nil
+}
+
+func randomLatLng(rng *rand.Rand) s2.LatLng {
+	lat := math.Asin(2*rng.Float64()-1) * 180 / math.Pi
+	return s2.LatLngFromDegrees(lat, rng.Float64()*360-180)
+}
+
+func regionContains(r s2.Region, ll s2.LatLng) bool {
+	if rect, ok := r.(s2.Rect); ok {
+		// 境界上の点は浮動小数点の誤差でわずかに外れることがある
+		return rect.DistanceToLatLng(ll) < 1e-12
+	}
+	return r.ContainsPoint(s2.PointFromLatLng(ll))
+}
+
+func regionCenter(r s2.Region) s2.LatLng {
+	switch v := r.(type) {
+	case s2.Rect:
+		return v.Center()
+	case s2.Cell:
+		return s2.LatLngFromPoint(v.Center())
+	}
+	return s2.LatLngFromPoint(r.CapBound().Center())
+}
+
+// Covered は s のトピック名が filters（Filters の結果）のいずれかにマッチする位置かを返す関数を生成する
+func Covered(s Scheme, filters []string) func(lat, lng float64) bool {
+	set := make(map[string]bool, len(filters))
+	for _, f := range filters {
+		set[f] = true
+	}
+	return func(lat, lng float64) bool {
+		return matchAny(set, s.Topic(s2.LatLngFromDegrees(lat, lng)))
+	}
+}
+
+// matchAny は topic の上位の階層のいずれかが "<上位の階層>/#" の形のトピックフィルタとして filters に含まれるかを返す
+func matchAny(filters map[string]bool, topic string) bool {
+	for i := len(topic); i > 0; i = strings.LastIndex(topic[:i], "/") {
+		if filters[topic[:i]+"/#"] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+// Package trace は車両や歩行者の移動軌跡を読み込み、各時点の位置を求める
+// 軌跡は GeoJSON の LineString、または id, timestamp, lat, lng の列を持つ CSV から読み込む
+package trace
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point は軌跡上の 1 点
+type Point struct {
+	// T は全ての軌跡のうち最も早い時刻からの経過時間
+	T   time.Duration
+	Lat float64
+	Lng float64
+}
+
+// Trace は 1 つの ID の軌跡（時刻順）
+type Trace struct {
+	ID     string
+	Points []Point
+}
+
+// Start は軌跡の最初の時刻を返す
+func (t *Trace) Start() time.Duration {
+	return t.Points[0].T
+}
+
+// End は軌跡の最後の時刻を返す
+func (t *Trace) End() time.Duration {
+	return t.Points[len(t.Points)-1].T
+}
+
+// At は時刻 d の位置を前後の点から線形に補間して返す
+// 軌跡の期間外の場合は最初または最後の点を返す
+func (t *Trace) At(d time.Duration) (lat, lng float64) {
+	ps := t.Points
+	i := sort.Search(len(ps), func(i int) bool { return ps[i].T > d })
+	switch {
+	case i == 0:
+		return ps[0].Lat, ps[0].Lng
+	case i == len(ps):
+		return ps[i-1].Lat, ps[i-1].Lng
+	}
+	a, b := ps[i-1], ps[i]
+	r := float64(d-a.T) / float64(b.T-a.T)
+	return a.Lat + (b.Lat-a.Lat)*r, a.Lng + (b.Lng-a.Lng)*r
+}
+
+// Load は path の軌跡を読み込み、ID 順に返す
+// 拡張子が .csv の場合は CSV、それ以外は GeoJSON として読み込む
+func Load(path string) ([]*Trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// 時刻の単位を揃えるため、読み込んだ時点では UNIX 時間[ns] とする
+	var byID map[string][]Point
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		byID, err = readCSV(f)
+	} else {
+		byID, err = readGeoJSON(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if len(byID) == 0 {
+		return nil, TraceError{fmt.Sprintf("%v: no traces found", path)}
+	}
+
+	origin := time.Duration(math.MaxInt64)
+	traces := make([]*Trace, 0, len(byID))
+	for id, ps := range byID {
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].T < ps[j].T })
+		if ps[0].T < origin {
+			origin = ps[0].T
+		}
+		traces = append(traces, &Trace{ID: id, Points: ps})
+	}
+	for _, t := range traces {
+		for i := range t.Points {
+			t.Points[i].T -= origin
+		}
+	}
+	sort.Slice(traces, func(i, j int) bool { return traces[i].ID < traces[j].ID })
+	return traces, nil
+}
+
+// readCSV は見出し行に id, timestamp, lat, lng（別名: time, latitude, lon, longitude）の列を持つ CSV を読み込む
+func readCSV(r io.Reader) (map[string][]Point, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "id":
+			cols["id"] = i
+		case "timestamp", "time":
+			cols["time"] = i
+		case "lat", "latitude":
+			cols["lat"] = i
+		case "lng", "lon", "longitude":
+			cols["lng"] = i
+		}
+	}
+	for _, name := range []string{"id", "time", "lat", "lng"} {
+		if _, ok := cols[name]; !ok {
+			return nil, TraceError{fmt.Sprintf("missing column %q (header: %v)", name, strings.Join(header, ","))}
+		}
+	}
+	byID := map[string][]Point{}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseTimestamp(rec[cols["time"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		lat, err := strconv.ParseFloat(rec[cols["lat"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		lng, err := strconv.ParseFloat(rec[cols["lng"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		id := rec[cols["id"]]
+		byID[id] = append(byID[id], Point{T: t, Lat: lat, Lng: lng})
+	}
+	return byID, nil
+}
+
+type feature struct {
+	ID         interface{}            `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   struct {
+		Type        string      `json:"type"`
+		Coordinates [][]float64 `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+// readGeoJSON は LineString の Feature（または FeatureCollection）を読み込む
+// 各点の時刻は properties の times（または timestamps）の配列、無い場合は座標の 4 番目の要素 [lng, lat, 高さ, 時刻] とする
+// ID は properties の id、無い場合は Feature の id、いずれも無い場合は Feature の順番とする
+func readGeoJSON(r io.Reader) (map[string][]Point, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var root struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	switch root.Type {
+	case "FeatureCollection":
+	case "Feature":
+		var f feature
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, err
+		}
+		root.Features = []feature{f}
+	default:
+		return nil, TraceError{fmt.Sprintf("unsupported GeoJSON type %q (expected: FeatureCollection, Feature)", root.Type)}
+	}
+	byID := map[string][]Point{}
+	for i, f := range root.Features {
+		if f.Geometry.Type != "LineString" {
+			return nil, TraceError{fmt.Sprintf("feature %v: unsupported geometry %q (expected: LineString)", i, f.Geometry.Type)}
+		}
+		id := fmt.Sprint(i)
+		if v, ok := f.Properties["id"]; ok && v != nil {
+			id = fmt.Sprint(v)
+		} else if f.ID != nil {
+			id = fmt.Sprint(f.ID)
+		}
+		times, ok := f.Properties["times"].([]interface{})
+		if !ok {
+			times, _ = f.Properties["timestamps"].([]interface{})
+		}
+		if times != nil && len(times) != len(f.Geometry.Coordinates) {
+			return nil, TraceError{fmt.Sprintf("feature %v: %v times for %v coordinates", i, len(times), len(f.Geometry.Coordinates))}
+		}
+		for j, c := range f.Geometry.Coordinates {
+			if len(c) < 2 {
+				return nil, TraceError{fmt.Sprintf("feature %v: invalid coordinate %v", i, c)}
+			}
+			var t time.Duration
+			switch {
+			case times != nil:
+				if t, err = parseTimestamp(fmt.Sprint(times[j])); err != nil {
+					return nil, fmt.Errorf("feature %v: %v", i, err)
+				}
+			case len(c) >= 4:
+				t = unixTime(c[3])
+			default:
+				return nil, TraceError{fmt.Sprintf("feature %v: no timestamps (expected: properties.times or [lng, lat, elevation, time] coordinates)", i)}
+			}
+			byID[id] = append(byID[id], Point{T: t, Lat: c[1], Lng: c[0]})
+		}
+	}
+	return byID, nil
+}
+
+// parseTimestamp は UNIX 時間（秒 または ミリ秒）または RFC3339 形式の時刻を UNIX 時間[ns] にする
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return unixTime(v), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, TraceError{fmt.Sprintf("invalid timestamp %q (expected: UNIX time [sec] or [ms], or RFC3339)", s)}
+	}
+	return time.Duration(t.UnixNano()), nil
+}
+
+// unixTime は UNIX 時間を UNIX 時間[ns] にする
+// 1e11 以上の値はミリ秒とみなす（秒では西暦 5000 年以降にあたるため）
+func unixTime(v float64) time.Duration {
+	if math.Abs(v) >= 1e11 {
+		return time.Duration(v * float64(time.Millisecond))
+	}
+	return time.Duration(v * float64(time.Second))
+}
+
+// TraceError は軌跡を読み込めないことを表す
+type TraceError struct {
+	Msg string
+}
+
+func (e TraceError) Error() string {
+	return fmt.Sprintf("Error: %v", e.Msg)
+}
@@ -0,0 +1,191 @@
+package trace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// near は浮動小数点数から変換した時刻の丸め誤差（1µs 未満）を許容して比較する
+func near(a, b time.Duration) bool {
+	d := a - b
+	return -time.Microsecond < d && d < time.Microsecond
+}
+
+func TestUnixTime(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want time.Duration
+	}{
+		{0, 0},
+		{1.5, 1500 * time.Millisecond},
+		{1760000000, 1760000000 * time.Second},
+		// 1e11 未満は秒、以上はミリ秒
+		{9000000000, 9000000000 * time.Second},
+		{1e11, 1e8 * time.Second},
+		{1760000000123, 1760000000123 * time.Millisecond},
+		{-1760000000123, -1760000000123 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := unixTime(tt.v); !near(got, tt.want) {
+			t.Errorf("unixTime(%v) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		s    string
+		ok   bool
+		want time.Duration
+	}{
+		{"1760000000", true, 1760000000 * time.Second},
+		{" 1760000000.25 ", true, 1760000000250 * time.Millisecond},
+		{"1760000000123", true, 1760000000123 * time.Millisecond},
+		{"1.760000000123e+12", true, 1760000000123 * time.Millisecond},
+		{"2025-10-09T09:06:40Z", true, 1760000800 * time.Second},
+		{"2025-10-09T18:06:40.5+09:00", true, 1760000800500 * time.Millisecond},
+		{"2025-10-09 09:06:40", false, 0},
+		{"", false, 0},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.s)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("parseTimestamp(%q): err = %v, want ok = %v", tt.s, err, tt.ok)
+			continue
+		}
+		if !near(got, tt.want) {
+			t.Errorf("parseTimestamp(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestAt(t *testing.T) {
+	tr := &Trace{ID: "a", Points: []Point{
+		{T: 10 * time.Second, Lat: 0, Lng: 0},
+		{T: 20 * time.Second, Lat: 10, Lng: -20},
+		{T: 20 * time.Second, Lat: 30, Lng: 40},
+		{T: 40 * time.Second, Lat: 50, Lng: 40},
+	}}
+	tests := []struct {
+		d        time.Duration
+		lat, lng float64
+	}{
+		// 軌跡の期間外は最初または最後の点
+		{0, 0, 0},
+		{10 * time.Second, 0, 0},
+		{15 * time.Second, 5, -10},
+		{17500 * time.Millisecond, 7.5, -15},
+		// 同じ時刻の点が続く場合は後の点
+		{20 * time.Second, 30, 40},
+		{30 * time.Second, 40, 40},
+		{40 * time.Second, 50, 40},
+		{time.Hour, 50, 40},
+	}
+	for _, tt := range tests {
+		if lat, lng := tr.At(tt.d); lat != tt.lat || lng != tt.lng {
+			t.Errorf("At(%v) = (%v, %v), want (%v, %v)", tt.d, lat, lng, tt.lat, tt.lng)
+		}
+	}
+	if tr.Start() != 10*time.Second || tr.End() != 40*time.Second {
+		t.Errorf("Start, End = %v, %v, want 10s, 40s", tr.Start(), tr.End())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// want は ID ごとの各点の時刻[s]（µs 単位に丸める）と緯度
+	type want map[string][][2]float64
+	tests := []struct {
+		name, file, text string
+		ok               bool
+		want             want
+	}{
+		{"csv", "a.csv", "id,timestamp,lat,lng\nb,1760000010,3,4\na,1760000005,1,2\na,1760000000,0,0\n", true,
+			want{"a": {{0, 0}, {5, 1}}, "b": {{10, 3}}}},
+		{"csv aliases", "a.CSV", " ID , Time , Latitude , Longitude \nx,1760000000500,1,2\nx,2025-10-09T08:53:21Z,3,4\n", true,
+			want{"x": {{0, 1}, {0.5, 3}}}},
+		{"csv lon", "a.csv", "lon,lat,id,time\n2,1,x,10\n", true, want{"x": {{0, 1}}}},
+		{"csv missing column", "a.csv", "id,timestamp,lat\na,1,2\n", false, nil},
+		{"csv invalid timestamp", "a.csv", "id,timestamp,lat,lng\na,yesterday,1,2\n", false, nil},
+		{"csv invalid lat", "a.csv", "id,timestamp,lat,lng\na,1,north,2\n", false, nil},
+		{"csv empty", "a.csv", "id,timestamp,lat,lng\n", false, nil},
+		{"feature collection", "a.geojson", `{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "id": "f", "properties": {"id": "p", "times": [1760000000000, 1760000001000]},
+			 "geometry": {"type": "LineString", "coordinates": [[2, 1], [4, 3]]}},
+			{"type": "Feature", "id": 7, "properties": {"timestamps": ["2025-10-09T09:06:40Z"]},
+			 "geometry": {"type": "LineString", "coordinates": [[6, 5]]}},
+			{"type": "Feature", "properties": {},
+			 "geometry": {"type": "LineString", "coordinates": [[8, 7, 0, 1760000800.5]]}}]}`, true,
+			want{"p": {{0, 1}, {1, 3}}, "7": {{800, 5}}, "2": {{800.5, 7}}}},
+		{"feature", "a.json", `{"type": "Feature", "properties": {"id": null},
+			"geometry": {"type": "LineString", "coordinates": [[2, 1, 0, 1760000001000], [4, 3, 0, 1760000000000]]}}`, true,
+			want{"0": {{0, 3}, {1, 1}}}},
+		{"no timestamps", "a.geojson", `{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[2, 1]]}}`, false, nil},
+		{"times length", "a.geojson", `{"type": "Feature", "properties": {"times": [1]},
+			"geometry": {"type": "LineString", "coordinates": [[2, 1], [4, 3]]}}`, false, nil},
+		{"point", "a.geojson", `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [2, 1]}}`, false, nil},
+		{"geometry collection", "a.geojson", `{"type": "GeometryCollection"}`, false, nil},
+		{"empty collection", "a.geojson", `{"type": "FeatureCollection", "features": []}`, false, nil},
+		{"invalid json", "a.geojson", `{"type": `, false, nil},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := ioutil.WriteFile(path, []byte(tt.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		traces, err := Load(path)
+		os.Remove(path)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%v: Load: err = %v, want ok = %v", tt.name, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		got := want{}
+		ids := []string{}
+		for _, tr := range traces {
+			ids = append(ids, tr.ID)
+			for _, p := range tr.Points {
+				got[tr.ID] = append(got[tr.ID], [2]float64{p.T.Round(time.Microsecond).Seconds(), p.Lat})
+			}
+		}
+		if !equal(got, tt.want) {
+			t.Errorf("%v: Load = %v (IDs %v), want %v", tt.name, got, ids, tt.want)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i-1] >= ids[i] {
+				t.Errorf("%v: IDs %v are not sorted", tt.name, ids)
+			}
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Errorf("Load of a missing file succeeded, want error")
+	}
+}
+
+func equal(a, b map[string][][2]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, ps := range a {
+		if len(ps) != len(b[id]) {
+			return false
+		}
+		for i := range ps {
+			if ps[i] != b[id][i] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -1,35 +1,24 @@
 #!/bin/bash
 
-ARG="${1} ${2} ${3} ${4} ${5} ${6} ${7} ${8} ${9} ${10} ${11} ${12} ${13} ${14} ${15} ${16} ${17} ${18} ${19} ${20}"
-EXECFILE="main.go"
-EXECFILE_DIR="cmd/single-publisher/"
+ARG="-backend single ${1} ${2} ${3} ${4} ${5} ${6} ${7} ${8} ${9} ${10} ${11} ${12} ${13} ${14} ${15} ${16} ${17} ${18} ${19} ${20}"
+EXECFILE="bin/lbmqtt-eval"
 LOGFILE_DIR="logs/single/pub/$(python3 -c "from datetime import datetime as dt;print(dt.now().strftime('%Y-%m-%d/%H'))")"
 LOGFILE="${LOGFILE_DIR}/$(python3 -c "from datetime import datetime as dt;print(dt.now().strftime('%Y%m%d-%H%M%S-%f'))").log"
-LOGFILE_SIG="logs/`hostname`.logs.sig"
-SIG_CMD="md5sum"
-if [ `uname` = "Darwin" ]; then
-	SIG_CMD="md5"  # MacOS を想定
-fi
+MANIFEST="${LOGFILE%.log}.manifest.json"
+LEDGER="logs/`hostname`.ledger"
 
 if [ ! -d ${LOGFILE_DIR} ]; then
     mkdir -p ${LOGFILE_DIR}
 fi
-SELF_MD5SUM=`${SIG_CMD} ${0}`
-cd ${EXECFILE_DIR}
-EXECFILE_MD5SUM=`${SIG_CMD} ${EXECFILE}`
-go run ${EXECFILE} ${ARG} | tee -a ../../${LOGFILE}
-cd -
-LOGFILE_SIG_MD5SUM=`${SIG_CMD} ${LOGFILE_SIG}`
-LOGFILE_SIG_LEN=`cat ${LOGFILE_SIG} | wc -l`
+# 計測の直前にコンパイルしないよう、先にビルドしておく（pub と sub のスクリプトが同時にビルドしても壊れないよう rename で置き換える）
+go build -ldflags "-X location-based-mqtt-evaluation-tool/internal/provenance.Revision=`git describe --always --dirty 2>/dev/null`" -o ${EXECFILE}.$$ ./cmd/lbmqtt-eval && mv ${EXECFILE}.$$ ${EXECFILE} || exit 1
+${EXECFILE} pub -manifest ${MANIFEST} ${ARG} | tee -a ${LOGFILE}
 sleep 1
 
 # ログファイルの統計結果を追記、表示
 echo "" | tee -a ${LOGFILE} | tee -a ${LOGFILE}
 echo "########### Added by ${0} ###########" | tee -a ${LOGFILE}
-echo "${SIG_CMD} ${EXECFILE_MD5SUM}" | tee -a ${LOGFILE}
-echo "${SIG_CMD} ${SELF_MD5SUM}" | tee -a ${LOGFILE}
-echo "${SIG_CMD} ${LOGFILE_SIG_MD5SUM}" | tee -a ${LOGFILE}
-echo "Signature file length             : ${LOGFILE_SIG_LEN} (${LOGFILE_SIG})"
+echo "Manifest                          : ${MANIFEST}" | tee -a ${LOGFILE}
 echo "Current directory                 : `pwd`"
 cat ${LOGFILE} | grep -oE "OPTION .+$" | tee -a ${LOGFILE}
 cat ${LOGFILE} | grep -oE "[0-9]+ \[pub/s\]$" | \
@@ -39,7 +28,9 @@ awk '{
     }
 }
 END {
-    if(NR == 0) {
+    # 0 [pub/s] の行は x に含めないため、行数（NR）ではなく x の要素数で割る
+    n = length(x)
+    if(n == 0) {
         print "Average                           : --- [pub/sec] [n=0]"
         print "Variance                          : ---"
         print "Standard deviation                : ---"
@@ -48,30 +39,22 @@ END {
     for(i in x){
         sum_x += x[i]
     }
-    m_x = sum_x / NR
+    m_x = sum_x / n
     sum_dx2 = 0
     for(i in x){
         sum_dx2 += (x[i] - m_x) ^ 2
     }
 
-    print "Sum                               : " sum_x " [pub] [n=" NR "]"
-    print "Average                           : " m_x " [pub/sec] [n=" NR "]"
-    print "Variance                          : " sum_dx2 / NR
-    print "Standard deviation                : " sqrt(sum_dx2 / NR)
+    print "Sum                               : " sum_x " [pub] [n=" n "]"
+    print "Average                           : " m_x " [pub/sec] [n=" n "]"
+    print "Variance                          : " sum_dx2 / n
+    print "Standard deviation                : " sqrt(sum_dx2 / n)
 }' | tee -a ${LOGFILE}
 echo "MQTT Publish error num            : `cat ${LOGFILE} | grep 'MQTT Publish error' | wc -l`" | tee -a ${LOGFILE}
 echo "MQTT Connect error num            : `cat ${LOGFILE} | grep 'MQTT Connect error' | wc -l`" | tee -a ${LOGFILE}
 
-# システム情報をログファイルに保存（標準出力には表示しない）
-echo "" >> ${LOGFILE}
-echo "#### System Info ####" >> ${LOGFILE}
-if [ `uname` = "Darwin" ]; then
-    system_profiler SPHardwareDataType >> ${LOGFILE}
-else
-    cat /etc/lsb-release >> ${LOGFILE}
-    cat /proc/cpuinfo >> ${LOGFILE}
-    cat /proc/meminfo >> ${LOGFILE}
-fi
+# グラフと表をまとめたレポートを生成する
+${EXECFILE} report -o ${LOGFILE%.log}.html ${LOGFILE}
 
-# 生成したログファイルのハッシュを保存
-echo "${SIG_CMD} `${SIG_CMD} ${LOGFILE}`" >> ${LOGFILE_SIG}
+# ログファイルとマニフェスト（設定やシステム情報を含む）のハッシュを台帳に記録する
+${EXECFILE} seal -ledger ${LEDGER} ${LOGFILE} ${MANIFEST}
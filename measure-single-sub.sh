@@ -1,37 +1,27 @@
 #!/bin/bash
 
-ARG="${1} ${2} ${3} ${4} ${5} ${6} ${7} ${8} ${9} ${10} ${11} ${12} ${13} ${14} ${15} ${16} ${17} ${18} ${19} ${20}"
-EXECFILE="main.go"
-EXECFILE_DIR="cmd/single-subscriber/"
+ARG="-backend single -subR 0 ${1} ${2} ${3} ${4} ${5} ${6} ${7} ${8} ${9} ${10} ${11} ${12} ${13} ${14} ${15} ${16} ${17} ${18} ${19} ${20}"
+EXECFILE="bin/lbmqtt-eval"
 LOGFILE_DIR="logs/single/sub/$(python3 -c "from datetime import datetime as dt;print(dt.now().strftime('%Y-%m-%d/%H'))")"
 LOGFILE="${LOGFILE_DIR}/$(python3 -c "from datetime import datetime as dt;print(dt.now().strftime('%Y%m%d-%H%M%S-%f'))").log"
-LOGFILE_SIG="logs/`hostname`.logs.sig"
-SIG_CMD="md5sum"
-if [ `uname` = "Darwin" ]; then
-	# MacOS を想定
-	SIG_CMD="md5"
-fi
+MANIFEST="${LOGFILE%.log}.manifest.json"
+LEDGER="logs/`hostname`.ledger"
 
 if [ ! -d ${LOGFILE_DIR} ]; then
     mkdir -p ${LOGFILE_DIR}
 fi
-SELF_MD5SUM=`${SIG_CMD} ${0}`
-cd ${EXECFILE_DIR}
-EXECFILE_MD5SUM=`${SIG_CMD} ${EXECFILE}`
-go run ${EXECFILE} ${ARG} | tee -a ../../${LOGFILE}
-cd -
+# 計測の直前にコンパイルしないよう、先にビルドしておく（pub と sub のスクリプトが同時にビルドしても壊れないよう rename で置き換える）
+go build -ldflags "-X location-based-mqtt-evaluation-tool/internal/provenance.Revision=`git describe --always --dirty 2>/dev/null`" -o ${EXECFILE}.$$ ./cmd/lbmqtt-eval && mv ${EXECFILE}.$$ ${EXECFILE} || exit 1
+${EXECFILE} sub -manifest ${MANIFEST} ${ARG} | tee -a ${LOGFILE}
+# 判定条件（-assert、-assertFile）を満たさなかった場合は、後処理を終えた後に sub の終了コードを返す
+STATUS=${PIPESTATUS[0]}
 sleep 3  # publisher 側のスクリプトが終わるのを待つ
-LOGFILE_SIG_MD5SUM=`${SIG_CMD} ${LOGFILE_SIG}`
-LOGFILE_SIG_LEN=`cat ${LOGFILE_SIG} | wc -l`
 CLIENT_NUM=`cat ${LOGFILE} | grep -oE "OPTION Client num[ ]+:[ ]+[0-9]+" | sed -r "s/OPTION Client num[ ]+:[ ]+([0-9]+)/\1/g"`
 
 # ログファイルの統計結果を追記、表示
 echo "" | tee -a ${LOGFILE} | tee -a ${LOGFILE}
 echo "###################### Added by ${0} ######################" | tee -a ${LOGFILE}
-echo "${SIG_CMD} ${EXECFILE_MD5SUM}" | tee -a ${LOGFILE}
-echo "${SIG_CMD} ${SELF_MD5SUM}" | tee -a ${LOGFILE}
-echo "${SIG_CMD} ${LOGFILE_SIG_MD5SUM}" | tee -a ${LOGFILE}
-echo "Signature file length             : ${LOGFILE_SIG_LEN} (${LOGFILE_SIG})"
+echo "Manifest                          : ${MANIFEST}" | tee -a ${LOGFILE}
 echo "Current directory                 : `pwd`"
 cat ${LOGFILE} | grep -oE "OPTION .+$" | tee -a ${LOGFILE}
 echo "MQTT Publish error num            : `cat ${LOGFILE} | grep 'MQTT Publish error' | wc -l`" | tee -a ${LOGFILE}
@@ -140,16 +130,10 @@ END {
     print ENDLINE
 }' | tee -a ${LOGFILE}
 
-# システム情報をログファイルに保存（標準出力には表示しない）
-echo "" >> ${LOGFILE}
-echo "#### System Info ####" >> ${LOGFILE}
-if [ `uname` = "Darwin" ]; then
-    system_profiler SPHardwareDataType >> ${LOGFILE}
-else
-    cat /etc/lsb-release >> ${LOGFILE}
-    cat /proc/cpuinfo >> ${LOGFILE}
-    cat /proc/meminfo >> ${LOGFILE}
-fi
+# グラフと表をまとめたレポートを生成する
+${EXECFILE} report -o ${LOGFILE%.log}.html ${LOGFILE}
+
+# ログファイルとマニフェスト（設定やシステム情報を含む）のハッシュを台帳に記録する
+${EXECFILE} seal -ledger ${LEDGER} ${LOGFILE} ${MANIFEST}
 
-# 生成したログファイルのハッシュを保存
-echo "${SIG_CMD} `${SIG_CMD} ${LOGFILE}`" >> ${LOGFILE_SIG}
+exit ${STATUS}